@@ -1,38 +1,77 @@
 package agent
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
 )
 
 // GobAgent communicates with GobServer (from your code)
 // about changes to template files
 type GobAgent struct {
-	// The port GobAgent binds to
-	Addr string
+	// The URL of the GobServer's event stream
+	ServerAddr string
 
 	// The function GobAgent should execute then it receives
 	// a message about updated template files
 	HandleFunc func(files []string)
+
+	// Deprecated: agents no longer listen on a port of their own,
+	// Addr is ignored
+	Addr string
 }
 
-// Creates a new GobAgent which will bind to the specified port
+// NewGobAgentWithServer creates a new GobAgent which will connect to the
+// GobServer at the given address. The address is either "host:port"
+// (e.g. "localhost:9034" or "devbox:9034") or the URL of the GobServer
+func NewGobAgentWithServer(serverAddr string) *GobAgent {
+	return &GobAgent{
+		ServerAddr: eventsURL(serverAddr),
+	}
+}
+
+// StartGobAgent handles all the boilerplate normally required to start up
+// a GobAgent that connects to the GobServer at the given address
+// (see NewGobAgentWithServer). It never returns
+func StartGobAgent(serverAddr string, f func([]string)) {
+	ga := NewGobAgentWithServer(serverAddr)
+	ga.SetHandleFunc(f)
+	ga.Listen()
+}
+
+// NewGobAgent creates a new GobAgent that used to bind to the specified port.
+//
+// Deprecated: agents no longer listen on a port of their own,
+// use NewGobAgentWithServer
 func NewGobAgent(port string) *GobAgent {
 	return &GobAgent{
 		Addr: fmt.Sprintf(":%s", port),
 	}
 }
 
-// StartGobAgentWithFunc handles all the boilerplate normally required
-// to start up a GobAgent
+// StartGobAgentWithFunc starts up a GobAgent that connects to
+// the GobServer on localhost.
+//
+// Deprecated: agentPort is ignored, use StartGobAgent
 func StartGobAgentWithFunc(agentPort, serverPort string, f func([]string)) {
-	ga := NewGobAgent(agentPort)
-	ga.SetHandleFunc(f)
-	ga.Start(serverPort)
+	StartGobAgent("localhost:"+serverPort, f)
+}
+
+// eventsURL returns the URL of the event stream of the GobServer at addr
+func eventsURL(addr string) string {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return strings.TrimSuffix(addr, "/") + "/events"
 }
 
 // SetHandleFunc registers a function to call with the GobAgent when
@@ -42,65 +81,114 @@ func (ga *GobAgent) SetHandleFunc(f func([]string)) {
 	ga.HandleFunc = f
 }
 
-// Start sets up the web server for a GobAgent, subscribes to
-// the GobServer (if it's up) and begins to listen for updates
+// Listen subscribes to the GobServer and listens for updates. It never
+// returns: whenever the connection is lost (e.g. gob restarted),
+// it reconnects with a growing delay
+func (ga *GobAgent) Listen() {
+	delay := minReconnectDelay
+	for {
+		connected, err := ga.subscribe()
+		if connected {
+			delay = minReconnectDelay
+			fmt.Println("[gob] lost the connection to the gob server, reconnecting...")
+		} else if delay == minReconnectDelay {
+			// Only mention the first of a series of failed attempts
+			fmt.Println("[gob] cannot connect to the gob server, retrying...", err)
+		}
+
+		time.Sleep(delay)
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// Start subscribes to the GobServer listening on the given port
+// on localhost, unless ServerAddr is set, and listens for updates.
+//
+// Deprecated: use Listen
 func (ga *GobAgent) Start(serverPort string) {
-	http.HandleFunc("/update", ga.HandleUpdate)
+	if ga.ServerAddr == "" {
+		ga.ServerAddr = eventsURL("localhost:" + serverPort)
+	}
+	ga.Listen()
+}
 
-	if err := ga.Subscribe(serverPort); err != nil {
-		fmt.Println(err)
-		fmt.Println("[gob] Failed to connect to gob server, gob agent turning off...")
-		return
+// Subscribe starts listening for updates from the GobServer listening
+// on the given port on localhost, unless ServerAddr is set, in the background.
+//
+// Deprecated: use Listen
+func (ga *GobAgent) Subscribe(serverPort string) error {
+	if ga.ServerAddr == "" {
+		ga.ServerAddr = eventsURL("localhost:" + serverPort)
 	}
+	go ga.Listen()
+	return nil
+}
 
-	err := http.ListenAndServe(ga.Addr, nil)
+// subscribe connects to the event stream of the GobServer and calls the
+// registered function for every update, until the connection is lost.
+// It reports whether it managed to connect
+func (ga *GobAgent) subscribe() (bool, error) {
+	resp, err := http.Get(ga.ServerAddr)
 	if err != nil {
-		log.Fatal("ListenAndServ: ", err)
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	fmt.Println("[gob] connected to the gob server, listening for template updates...")
+
+	// Events are made up of "field: value" lines and end with a blank line
+	var name, data string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if name == "update" {
+				ga.handleUpdate([]byte(data))
+			}
+			name, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return true, err
 	}
+	return true, fmt.Errorf("the gob server closed the connection")
 }
 
 // HandleUpdate receives the files that have changed and
-// calls the registered function on them
+// calls the registered function on them.
+//
+// Deprecated: updates arrive over the connection made by Listen
 func (ga *GobAgent) HandleUpdate(w http.ResponseWriter, req *http.Request) {
-	var files map[string][]string
 	data, err := ioutil.ReadAll(req.Body)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
-	err = json.Unmarshal(data, &files)
-	if err != nil {
+	if err != nil || !ga.handleUpdate(data) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-
-	// TODO(ttacon): check for key existence
-	ga.HandleFunc(files["files"])
 	w.WriteHeader(http.StatusOK)
 }
 
-// Subscribe registers a GobAgent with a GobServer listening
-// on the given port
-func (ga *GobAgent) Subscribe(serverPort string) error {
-	client := http.Client{}
-	body := map[string]string{
-		"route": ga.Addr + "/update",
-	}
-	data, err := json.Marshal(&body)
-	if err != nil {
-		return err
-	}
-	req, err := http.NewRequest(
-		"POST",
-		fmt.Sprintf("http://localhost:%s/subscribe", serverPort),
-		bytes.NewReader(data))
-	if err != nil {
-		return err
+// handleUpdate calls the registered function with the changed files
+// of an update. It reports whether the update was valid
+func (ga *GobAgent) handleUpdate(data []byte) bool {
+	var files map[string][]string
+	if err := json.Unmarshal(data, &files); err != nil {
+		fmt.Println("[gob] received an invalid update:", err)
+		return false
 	}
-	_, err = client.Do(req)
-	if err != nil {
-		return err
+
+	if ga.HandleFunc != nil {
+		ga.HandleFunc(files["files"])
 	}
-	return nil
+	return true
 }
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// heartbeatInterval is how often an idle event stream gets a comment,
+// which keeps proxies from closing the connection
+const heartbeatInterval = 15 * time.Second
+
+// event is a single Server-Sent Event
+type event struct {
+	name string
+	data []byte
+}
+
+// broker fans events out to every connected event stream
+type broker struct {
+	mu      sync.Mutex
+	streams map[chan event]bool
+}
+
+func newBroker() *broker {
+	return &broker{
+		streams: make(map[chan event]bool),
+	}
+}
+
+// publish sends an event to every connected stream. A stream that
+// can't keep up misses the event rather than holding up the others
+func (b *broker) publish(name string, data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for stream := range b.streams {
+		select {
+		case stream <- event{name, data}:
+		default:
+		}
+	}
+}
+
+// count returns the number of connected streams
+func (b *broker) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.streams)
+}
+
+// ServeHTTP streams the published events to the client until it disconnects
+func (b *broker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported.", http.StatusInternalServerError)
+		return
+	}
+
+	stream := make(chan event, 16)
+	b.mu.Lock()
+	b.streams[stream] = true
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.streams, stream)
+		b.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	// Ask clients to reconnect quickly when gob restarts
+	fmt.Fprint(w, "retry: 1000\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case ev := <-stream:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, ev.data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case <-req.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// LiveReloadScript is served at /livereload.js. It listens to the
+// /livereload event stream of the GobServer it was loaded from, reloads the
+// page on "reload" events and reloads its stylesheets on "css-refresh" events
+const LiveReloadScript = `(function() {
+  var script = document.currentScript;
+  var origin = script ? new URL(script.src).origin : location.origin;
+  var source = new EventSource(origin + "/livereload");
+
+  source.addEventListener("reload", function() {
+    location.reload();
+  });
+
+  source.addEventListener("css-refresh", function() {
+    var links = document.querySelectorAll('link[rel="stylesheet"]');
+    for (var i = 0; i < links.length; i++) {
+      var url = new URL(links[i].href);
+      url.searchParams.set("gob", Date.now());
+      links[i].href = url.href;
+    }
+  });
+})();
+`
+
+// ServeLiveReloadScript serves LiveReloadScript
+func (gs *GobServer) ServeLiveReloadScript(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/javascript")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, LiveReloadScript)
+}
+
+// Reload tells the connected browsers to reload the page
+func (gs *GobServer) Reload() {
+	if gs.browsers.count() > 0 {
+		fmt.Println("[gob] reloading browsers...")
+	}
+	gs.browsers.publish("reload", []byte("{}"))
+}
+
+// RefreshCSS tells the connected browsers to reload their stylesheets
+// after the given files changed
+func (gs *GobServer) RefreshCSS(files []string) {
+	data, err := json.Marshal(map[string][]string{"files": files})
+	if err != nil {
+		panic(err)
+	}
+
+	if gs.browsers.count() > 0 {
+		fmt.Println("[gob] refreshing stylesheets in browsers...")
+	}
+	gs.browsers.publish("css-refresh", data)
+}
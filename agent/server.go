@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"sync"
 )
 
 // GobServer represents the single server gob
@@ -16,14 +17,24 @@ type GobServer struct {
 	// The port GobServer binds to
 	Addr string
 
-	// The hosts GobServer should update about template changes
+	// The GobAgents connected to be updated about template changes
+	agents *broker
+
+	// Deprecated: the routes of the GobAgents that subscribed with AddRoute.
+	// Current agents connect to the event stream at /events instead
 	SubscriberRoutes []string
+	routesMu         sync.Mutex // Guards SubscriberRoutes
+
+	// The browsers connected for live reload
+	browsers *broker
 }
 
 // Creates a new GobServer which will bind to the specified port
 func NewGobServer(port string) *GobServer {
 	return &GobServer{
-		Addr: fmt.Sprintf(":%s", port),
+		Addr:     fmt.Sprintf(":%s", port),
+		agents:   newBroker(),
+		browsers: newBroker(),
 	}
 }
 
@@ -31,14 +42,20 @@ func NewGobServer(port string) *GobServer {
 // subscribers and notifying messages. It provides a way
 // to hook third party templating engines into gob
 func (gs *GobServer) Start() {
+	http.Handle("/events", gs.agents)
 	http.HandleFunc("/subscribe", gs.AddRoute)
+	http.Handle("/livereload", gs.browsers)
+	http.HandleFunc("/livereload.js", gs.ServeLiveReloadScript)
 
 	fmt.Printf("[gob] starting up server on port %s\n", gs.Addr)
 	log.Fatal("ListenAndServ: ", http.ListenAndServe(gs.Addr, nil))
 }
 
 // AddRoute will register a particular route with the GobAgent to be
-// notified when a template gets re-rendered
+// notified when a template gets re-rendered.
+//
+// Deprecated: it only serves GobAgents built before agents connected
+// to the event stream at /events
 func (gs *GobServer) AddRoute(w http.ResponseWriter, req *http.Request) {
 	if req.Method == "POST" {
 		body, err := ioutil.ReadAll(req.Body)
@@ -54,38 +71,47 @@ func (gs *GobServer) AddRoute(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 
+		gs.routesMu.Lock()
 		gs.SubscriberRoutes = append(gs.SubscriberRoutes, data["route"])
+		gs.routesMu.Unlock()
 		fmt.Println("[gob] added subscriber to notify about template update...")
 	} else {
 		http.Error(w, "Post requests only.", 405)
 	}
 }
 
-// NotifiySubscribers will look through the list of Routes and send them each
-// a POST request with a JSON body that includes the list of source files
-// that need to be rerendered
+// NotifiySubscribers sends an "update" event with a JSON body that includes
+// the list of source files that need to be rerendered to every connected GobAgent
 func (gs *GobServer) NotifySubscribers(files []string) {
+	gs.routesMu.Lock()
+	routes := append([]string(nil), gs.SubscriberRoutes...)
+	gs.routesMu.Unlock()
+
 	// Only do work if we have subscribers
-	if len(gs.SubscriberRoutes) > 0 {
-		// For now, just attempt to make the POST
-		// and ignore all failures
-		fileMap := map[string][]string{
-			"files": files,
-		}
-		data, err := json.Marshal(&fileMap)
+	if gs.agents.count() == 0 && len(routes) == 0 {
+		fmt.Println("[gob] please hook into the gob agent for template rendering...")
+		return
+	}
+
+	fileMap := map[string][]string{
+		"files": files,
+	}
+	data, err := json.Marshal(&fileMap)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println("[gob] notifying agents about template update...")
+	gs.agents.publish("update", data)
+
+	// Agents that subscribed with AddRoute are sent a POST request.
+	// For now, just attempt to make the POST and ignore all failures
+	for _, route := range routes {
+		resp, err := http.Post("http://"+route, "application/json", bytes.NewReader(data))
 		if err != nil {
-			panic(err)
+			fmt.Println("[gob] cannot notify ", route, " because: ", err)
+			continue
 		}
-		for _, route := range gs.SubscriberRoutes {
-			fmt.Println("[gob] notifying agent on ", route)
-			resp, err := http.Post("http://"+route, "application/json", bytes.NewReader(data))
-			if err != nil {
-				fmt.Println("[gob] cannot notify ", route, " because: ", err)
-				continue
-			}
-			resp.Body.Close()
-		}
-	} else {
-		fmt.Println("[gob] please hook into the gob agent for template rendering...")
+		resp.Body.Close()
 	}
 }
@@ -1,25 +1,28 @@
 package gob
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/b1lly/gob/agent"
 	"github.com/b1lly/gob/dependencies"
-	"github.com/howeyc/fsnotify"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Gob struct {
 	GobServer  *agent.GobServer
-	Cmd        *exec.Cmd
+	Cmd        *Process            // The running application (see "process.go")
+	Procs      map[string]*Process // The running World processes keyed by package
 	CmdArgs    []string
 	Config     *Config   // See "config.go"
 	FlagConfig *GobFlags // See "config.go"
@@ -30,9 +33,29 @@ type Gob struct {
 	Filename string // The file name of the user input
 
 	PackagePath string   // The "package" path of the program we're building
+	ModuleRoot  string   // The directory containing go.mod (empty when building from GOPATH)
+	ModulePath  string   // The module path declared in go.mod
 	Binary      string   // The path to the binary file (e.g. output of build)
 	PkgDeps     []string // The 3rd-party dependencies of the package we're building
 	World       []string // All packages described in GobMultiPackage build file
+
+	WorldPkgs  map[string]*WorldPackage // The settings of each World package, keyed by package
+	worldGraph *dependencies.Graph      // Used to find the World packages affected by a change
+	testGraph  *dependencies.Graph      // Used to find the watched packages whose tests a change affects
+	filter     *fileFilter              // Decides which files and directories are watched (see "ignore.go")
+	changes    []string                 // The files that triggered the current rebuild (nil for the first build)
+	rules      []*compiledRule          // Maps changed files to what gob does about them (see "rules.go")
+	watchRoots []string                 // The package directories that are watched recursively
+	ruleRoots  []string                 // The directories the patterns of the filter and the rules are relative to
+	watcher    fileWatcher              // Set up by Watch
+	lastBuild  time.Time                // When the last successful build finished
+	proxy      *restartProxy            // Holds requests while the application restarts (see "proxy.go")
+	commands   chan string              // Run by the Watch loop, from stdin or a double Ctrl-C (see "console.go")
+
+	watchedMu sync.Mutex      // Guards watched
+	watched   map[string]bool // The directories that currently have a watcher
+
+	procsMu sync.Mutex // Guards Cmd and Procs
 }
 
 // NewGob returns a new instance of Gob
@@ -42,6 +65,8 @@ func NewGob(gobFlags *GobFlags) *Gob {
 	g := &Gob{
 		Config:     DefaultConfig(),
 		FlagConfig: gobFlags,
+		Procs:      make(map[string]*Process),
+		commands:   make(chan string, 1),
 	}
 	registerSignalHandlers(g)
 	return g
@@ -57,6 +82,11 @@ func (g *Gob) PrintErr(err error) {
 }
 
 func (g *Gob) checkIsSource(srcDir, buildDir, path string) ([]string, bool) {
+	// Prefer resolving through an enclosing go.mod before falling back to GOPATH
+	if toReturn, ok := g.checkIsModuleSource(buildDir, path); ok {
+		return toReturn, true
+	}
+
 	var absPath string
 	toReturn := make([]string, 4) // [0]=dir, [1]=filename, [2]=pkgPath [3]=binary
 	// Handle filename and "package" as inputs
@@ -126,19 +156,41 @@ func (g *Gob) IsValidSrc() bool {
 
 	// Check if multi-package build file
 	// currently must just be package def
-	data, err := ioutil.ReadFile(filepath.Join(g.Config.SrcDir, g.InputPath))
+	worldFile := g.InputPath
+	data, err := ioutil.ReadFile(worldFile)
+	if err != nil {
+		worldFile = filepath.Join(g.Config.SrcDir, g.InputPath)
+		data, err = ioutil.ReadFile(worldFile)
+	}
 	if err == nil {
-		var packagesToBuild []string
+		var packagesToBuild []*WorldPackage
 		err = json.Unmarshal(data, &packagesToBuild)
+
+		// A JSON array is a World file, even when one of its entries is invalid
+		if err != nil && bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+			fmt.Printf("[gob] '%s' is not a valid World build file: %v\n", g.InputPath, err)
+			return false
+		}
 		if err == nil {
-			g.World = packagesToBuild
+			// The entries can be import paths of the module the World file
+			// (or the current directory) is in
+			g.detectModule(filepath.Dir(worldFile), ".")
+
+			g.WorldPkgs = make(map[string]*WorldPackage)
 			// Make sure these are packages
 			badPackages := false
-			for _, pkgName := range g.World {
-				if _, isValidSrc := g.checkIsSource(g.Config.SrcDir, g.Config.BuildDir, pkgName); !isValidSrc {
-					fmt.Printf("[gob] '%s' is not a valid source file to build\n", pkgName)
+			for _, worldPkg := range packagesToBuild {
+				pkgValues, isValidSrc := g.checkIsSource(g.Config.SrcDir, g.Config.BuildDir, worldPkg.Package)
+				if !isValidSrc {
+					fmt.Printf("[gob] '%s' is not a valid source file to build\n", worldPkg.Package)
 					badPackages = true
+					continue
 				}
+
+				// Normalize relative paths to their import path
+				worldPkg.Package = pkgValues[2]
+				g.World = append(g.World, worldPkg.Package)
+				g.WorldPkgs[worldPkg.Package] = worldPkg
 			}
 			return !badPackages
 		}
@@ -147,6 +199,9 @@ func (g *Gob) IsValidSrc() bool {
 	// Store the users `runtime` flags
 	g.CmdArgs = nonGobArgs[1:]
 
+	// The input can be an import path of the module we're in
+	g.detectModule(".")
+
 	// Stores the absolute path of our file or package
 	// Used to check to see if the package/file exists from root
 	pkgValues, isValidSrc := g.checkIsSource(g.Config.SrcDir, g.Config.BuildDir, g.InputPath)
@@ -160,106 +215,247 @@ func (g *Gob) IsValidSrc() bool {
 }
 
 // Create a temp directory based on the configuration.
-// This is where all the binaries are stored
+// This is where all the binaries are stored. It also starts
+// forwarding signals to the application
 func (g *Gob) Setup() {
 	if _, err := os.Stat(g.Config.BuildDir); os.IsNotExist(err) {
 		g.Print("creating temporary build directory... " + g.Config.BuildDir)
 		os.MkdirAll(g.Config.BuildDir, 0777)
 	}
+
+	registerExitSignals(g, registerForwardedSignals(g))
+	g.startProxy()
+
+	if g.FlagConfig.AttachStdin && len(g.World) > 0 {
+		g.Print("stdin can only be attached to a single application, not a World")
+	}
+}
+
+// RootPkgs returns the packages that gob builds and runs
+func (g *Gob) RootPkgs() []string {
+	if len(g.World) == 0 {
+		return []string{g.PackagePath}
+	}
+	return g.World
 }
 
 // Build the source and save the binary
 func (g *Gob) Build() bool {
-	var pkgs []string
-	if len(g.World) == 0 {
-		pkgs = []string{g.PackagePath}
-	} else {
-		pkgs = g.World
+	return g.buildPkgs(g.RootPkgs())
+}
+
+// buildPkgs builds each of the given packages into the build directory,
+// running up to BuildJobs builds at the same time
+func (g *Gob) buildPkgs(pkgs []string) bool {
+	// A failing pre-build hook (e.g. code generation) aborts the build
+	if !g.runHooks("pre-build", g.FlagConfig.Hooks.PreBuild) {
+		g.proxy.buildFailed("a pre-build hook failed, see the output of gob")
+		return false
+	}
+
+	jobs := g.FlagConfig.BuildJobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
 	}
 
-	// keep track of which package build failed
-	buildSucceeded := true
+	// Buffer the output of each build when building several
+	// packages at once so that their errors don't get interleaved
+	buffered := len(pkgs) > 1 && jobs > 1
+
+	var (
+		wg           sync.WaitGroup
+		mu           sync.Mutex // Guards failures and the buffered output
+		sem          = make(chan struct{}, jobs)
+		failures     []string     // keep track of which package builds failed
+		failedOutput bytes.Buffer // the output of the failed builds
+	)
+
 	for _, pkg := range pkgs {
-		binaryName := filepath.Base(pkg)
-		cmd := exec.Command("go", "build", "-o", filepath.Join(g.Config.BuildDir, binaryName), pkg)
-		cmd.Stdout = g.Config.Stdout
-		cmd.Stderr = g.Config.Stderr
-
-		g.Print("building src... " + pkg)
-		if err := cmd.Run(); err != nil {
-			// TODO(ttacon): bulkify notifications
-			notifyFailed()
-			g.PrintErr(err)
-			cmd.Process.Kill()
-			cmd.Process.Wait()
-			buildSucceeded = false
-		} else {
-			notifyFixed()
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(pkg string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if output, ok := g.buildPkg(pkg, buffered, &mu); !ok {
+				mu.Lock()
+				failures = append(failures, pkg)
+				failedOutput.Write(output)
+				mu.Unlock()
+			}
+		}(pkg)
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		notifyFailed()
+		g.Print("failed to build " + strings.Join(failures, ", "))
+		g.proxy.buildFailed(failedOutput.String())
+		if g.proxy != nil {
+			// Show the browsers the build error page
+			g.reloadBrowsers()
 		}
+		return false
+	}
+
+	if !g.runHooks("post-build", g.FlagConfig.Hooks.PostBuild) {
+		g.proxy.buildFailed("a post-build hook failed, see the output of gob")
+		return false
+	}
+
+	g.lastBuild = time.Now()
+	g.proxy.buildFixed()
+	notifyFixed()
+	return true
+}
+
+// buildPkg runs `go build` for a single package and returns its output. When buffered,
+// the output of the build is written out in one piece (under outputMu) once it finishes
+func (g *Gob) buildPkg(pkg string, buffered bool, outputMu *sync.Mutex) ([]byte, bool) {
+	binary := filepath.Join(g.Config.BuildDir, g.binaryName(pkg))
+	options := g.buildOptions(pkg)
+
+	// Build next to the binary that may still be running and
+	// only replace it once the build has succeeded
+	next := binary + ".next"
+
+	args := []string{"build", "-o", next}
+	if len(options.BuildTags) > 0 {
+		args = append(args, "-tags", strings.Join(options.BuildTags, ","))
+	}
+	if options.LDFlags != "" {
+		args = append(args, "-ldflags", options.LDFlags)
+	}
+	args = append(args, options.BuildArgs...)
+	args = append(args, pkg)
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = g.ModuleRoot
+	if len(options.BuildEnv) > 0 {
+		cmd.Env = append(os.Environ(), options.BuildEnv...)
+	}
+
+	var output bytes.Buffer
+	if buffered {
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+	} else {
+		cmd.Stdout = io.MultiWriter(g.Config.Stdout, &output)
+		cmd.Stderr = io.MultiWriter(g.Config.Stderr, &output)
+	}
+
+	g.Print("building src... " + pkg)
+	err := cmd.Run()
+
+	if buffered && output.Len() > 0 {
+		outputMu.Lock()
+		fmt.Fprintf(g.Config.Stderr, "[gob] output of %s:\n", pkg)
+		g.Config.Stderr.Write(output.Bytes())
+		outputMu.Unlock()
+	}
+
+	if err != nil {
+		os.Remove(next)
+		g.PrintErr(fmt.Errorf("%s: %v", pkg, err))
+		fmt.Fprintf(&output, "%s: %v\n", pkg, err)
+		return output.Bytes(), false
+	}
+
+	// A running process keeps using the binary it was started from
+	if err := os.Rename(next, binary); err != nil {
+		g.PrintErr(fmt.Errorf("%s: %v", pkg, err))
+		fmt.Fprintf(&output, "%s: %v\n", pkg, err)
+		return output.Bytes(), false
 	}
-	return buildSucceeded
+
+	return output.Bytes(), true
 }
 
 // Run will attempt to run the binary that was previously compiled by Gob.
 func (g *Gob) Run() {
+	// Pass the requests held by the proxy on once the application is ready
+	defer g.proxy.release()
+
 	if g.FlagConfig.NoRunMode {
 		g.Print("waiting for changes to recompile...")
 		return
 	}
 
-	if len(g.World) == 0 {
-		cmd := exec.Command(g.Binary, g.CmdArgs...)
-		cmd.Stdout = g.Config.Stdout
-		cmd.Stderr = g.Config.Stderr
+	if !g.runHooks("pre-run", g.FlagConfig.Hooks.PreRun) {
+		return
+	}
 
+	if len(g.World) == 0 {
 		g.Print("starting application...")
-		if err := cmd.Start(); err != nil {
+		proc, err := g.startProcess("", g.appCommand, 0)
+		if err != nil {
 			notifyFailed()
 			g.PrintErr(err)
-			cmd.Process.Kill()
-			cmd.Process.Release()
-			g.Cmd = nil
+			g.setCmd(nil)
 		} else {
-			// TODO(ttacon): keep track of state so we actually know
-			// when it's fixed vs just not failing
-			notifyFixed()
-			g.Cmd = cmd
-		}
-	} else {
-		for _, pkgName := range g.World {
-			binaryName := filepath.Base(pkgName)
-			cmd := exec.Command(filepath.Join(g.Config.BuildDir, binaryName))
-			cmd.Stdout = g.Config.Stdout
-			cmd.Stderr = g.Config.Stderr
-
-			// pair pkgnames with cmd
-			g.Print("starting " + pkgName + "[" + binaryName + "]...")
-			if err := cmd.Start(); err != nil {
-				// TODO(ttacon): bulkify notifications when "running the world"
-				notifyFailed()
-				g.PrintErr(err)
-				cmd.Process.Kill()
-				cmd.Process.Wait()
-				g.Cmd = nil
-			} else {
+			g.setCmd(proc)
+			if g.waitReady(proc) {
 				notifyFixed()
-				g.Cmd = cmd
+				g.reloadBrowsers()
 			}
 		}
+	} else {
+		g.runWorld(g.World)
+	}
+}
+
+// runWorld starts the binaries of the given World packages
+// and waits for all of them to become ready
+func (g *Gob) runWorld(pkgs []string) {
+	var (
+		procs  []*Process
+		failed bool
+	)
+	for _, pkgName := range pkgs {
+		pkgName := pkgName
+		binaryName := g.binaryName(pkgName)
+		newCmd := func() *exec.Cmd {
+			return g.worldCommand(pkgName)
+		}
+
+		// pair pkgnames with cmd
+		g.Print("starting " + pkgName + "[" + binaryName + "]...")
+		proc, err := g.startProcess(pkgName, newCmd, 0)
+		if err != nil {
+			notifyFailed()
+			g.PrintErr(err)
+			g.setProc(pkgName, nil)
+			failed = true
+		} else {
+			g.setProc(pkgName, proc)
+			procs = append(procs, proc)
+		}
+	}
+
+	if g.waitReady(procs...) && !failed {
+		notifyFixed()
+		g.reloadBrowsers()
+	}
+}
+
+// appCommand returns the command that runs the application binary
+func (g *Gob) appCommand() *exec.Cmd {
+	cmd := exec.Command(g.Binary, g.CmdArgs...)
+	cmd.Stdout = g.Config.Stdout
+	cmd.Stderr = g.Config.Stderr
+	if g.FlagConfig.AttachStdin {
+		cmd.Stdin = os.Stdin
 	}
+	return cmd
 }
 
 // GetPkgDeps will return all of the dependencies for the root packages
 // that we're building and running
 func (g *Gob) GetPkgDeps() {
-	var pkgsToCheck []string
-
 	// Check if we're building multiple packages or just one package
-	if len(g.World) == 0 {
-		pkgsToCheck = []string{g.PackagePath}
-	} else {
-		pkgsToCheck = g.World
-	}
+	pkgsToCheck := g.RootPkgs()
 
 	// Filter down the list of dependencies to 10 based on
 	// some prioritization algorithms
@@ -268,6 +464,7 @@ func (g *Gob) GetPkgDeps() {
 		Graph: dependencies.NewGraph(&dependencies.Graph{
 			StdLib: false,
 			SrcDir: g.Config.SrcDir,
+			Dir:    g.ModuleRoot,
 			Pkgs:   pkgsToCheck,
 		}),
 	})
@@ -278,167 +475,386 @@ func (g *Gob) GetPkgDeps() {
 // Watch the filesystem for any changes
 // and restart the application if detected
 func (g *Gob) Watch() {
-	watcher, err := fsnotify.NewWatcher()
+	watcher, err := g.newFileWatcher()
 	if err != nil {
-		log.Fatal(err)
+		g.PrintErr(err)
+		g.exit(1)
 	}
+	g.watcher = watcher
 
-	done := make(chan bool)
+	// The directories of the packages we're building
+	g.watchRoots = nil
+	for _, pkg := range g.RootPkgs() {
+		g.watchRoots = append(g.watchRoots, g.pkgDir(pkg))
+	}
 
-	// Process events
-	go func() {
-		// Used to keep track of previous updates
-		var lastUpdate time.Time
+	// The patterns of the filter and the rules are relative to each package
+	// directory, which includes the dependencies that are being watched
+	g.ruleRoots = append([]string(nil), g.watchRoots...)
+	for _, dep := range g.PkgDeps {
+		g.ruleRoots = append(g.ruleRoots, g.pkgDir(dep))
+	}
+	g.filter = g.newFileFilter(g.ruleRoots)
+	g.rules, err = g.compileRules(g.ruleRoots)
+	if err != nil {
+		g.PrintErr(err)
+		g.exit(1)
+	}
+	g.watched = make(map[string]bool)
+
+	// Keep track of what each World package imports so that
+	// only the affected packages get rebuilt
+	if len(g.World) > 0 {
+		g.worldGraph = dependencies.NewGraph(&dependencies.Graph{
+			StdLib: false,
+			SrcDir: g.Config.SrcDir,
+			Dir:    g.ModuleRoot,
+			Pkgs:   g.World,
+		})
+	}
 
-		// Used as a buffer to track multiple unique file changes
-		var fileChanges []string
+	// Changes are handed off in batches once they've quieted down
+	changes := newDebouncer(time.Duration(g.FlagConfig.Debounce) * time.Millisecond)
 
+	// Process events
+	go func() {
 		for {
 			select {
-			case ev := <-watcher.Event:
+			case ev := <-watcher.Events():
+				// Keep our watchers in sync with the directories
+				// that are created and removed
+				newFiles := g.handleDirEvent(watcher, ev)
+
 				// Short circuit if a file is renamed
 				if ev.IsRename() {
 					break
 				}
 
-				_, file := filepath.Split(ev.Name)
-				bulkChange := purgeChanges(watcher)
-
 				// Buffer up a bunch of files from our events
-				// until the next update
-				fileChanges = append(fileChanges, ev.Name)
-				fileChanges = append(fileChanges, bulkChange...)
-
-				// Avoid excess rebuilds (.5 seconds)
-				if time.Since(lastUpdate).Nanoseconds() > 500000000 {
-					lastUpdate = time.Now()
-
-					app, views := g.getChangeType(fileChanges)
-					// Ignore hidden files
-					// TODO(billy) Figure out why this prevents duplicate events
-					if !strings.HasPrefix(file, ".") {
-						// If they are application files, rebuild
-						if app {
-							g.Print("restarting application...")
-							if g.Cmd != nil {
-								g.Cmd.Process.Kill()
-								g.Cmd.Process.Wait()
-								g.Cmd.Process.Release()
-							}
-							build := g.Build()
-							if build {
-								g.Run()
-							}
-						}
-
-						// Talk to the Gob Agent when a view has been updated
-						// and notify the subscribers
-						if len(views) > 0 && g.GobServer != nil {
-							g.GobServer.NotifySubscribers(views)
-						}
-					}
-
-					// Empty the files that were updated
-					fileChanges = nil
-				}
-			case err := <-watcher.Error:
+				// until the next update, dropping the ones we ignore
+				changes.add(g.filter.filterChanges(append([]string{ev.Name}, newFiles...))...)
+			case err := <-watcher.Errors():
 				log.Println("error:", err)
 			}
 		}
 	}()
 
 	// Our watchers require absolute paths for our dependencies
-	toWatch := make([]string, len(g.PkgDeps)+1)
-	for i, dep := range g.PkgDeps {
-		toWatch[i] = path.Join(g.Config.SrcDir, dep)
+	for _, dep := range g.PkgDeps {
+		err = watcher.Watch(g.pkgDir(dep))
+		if err != nil {
+			g.PrintErr(err)
+			g.exit(1)
+		}
 	}
 
-	// The last package to watch is the application package
-	toWatch[len(toWatch)-1] = path.Join(g.Config.SrcDir, g.PackagePath)
+	// If it's one of our application packages, recursively
+	// watch all sub directories
+	for _, root := range g.watchRoots {
+		_, err = g.watchTree(watcher, root)
+		if err != nil {
+			g.PrintErr(err)
+			g.exit(1)
+		}
+	}
 
-	// Create watchers in each of the packages
-	for i, path := range toWatch {
-		if i < len(toWatch)-1 {
-			err = watcher.Watch(path)
-			if err != nil {
-				log.Fatal(err)
-			}
-		} else {
-			// If it's our application package, recursively
-			// watch all sub directories
-			f := func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
+	// The code the World packages share has to be watched as well
+	g.watchWorldImports()
 
-				// Ignore hidden directories
-				// and only drop watchers in directories
-				if strings.HasPrefix(filepath.Base(path), ".") {
-					return filepath.SkipDir
-				}
+	// Commands are handled in between batches of changes
+	g.readCommands()
 
-				if info.IsDir() {
-					return watcher.Watch(path)
-				}
+	// Changes that come in while we're rebuilding are
+	// buffered up and handled in the next batch
+	for {
+		select {
+		case <-changes.ready:
+			g.handleChanges(changes.take())
+		case command := <-g.commands:
+			g.runConsoleCommand(command)
+		}
+	}
+}
 
-				return nil
-			}
+// handleChanges does whatever the rules say should happen
+// for a batch of changed files
+func (g *Gob) handleChanges(fileChanges []string) {
+	changes := g.classifyChanges(fileChanges)
 
-			filepath.Walk(path, f)
-			if err != nil {
-				log.Fatal(err)
-			}
+	for command, files := range changes.commands {
+		g.runCommand(command, files)
+	}
+
+	// If they are application files, rebuild
+	if len(changes.rebuild) > 0 {
+		// Let the hooks know what changed
+		g.changes = fileChanges
+		g.restartChanged(changes.rebuild)
+		g.changes = nil
+
+		// Rerun the tests of every package the change could have broken
+		if g.FlagConfig.TestMode {
+			g.testChanged(changes.rebuild)
 		}
+	} else if len(changes.restart) > 0 {
+		g.restartOnly(changes.restart)
 	}
 
-	<-done
+	// Talk to the Gob Agent when a view has been updated
+	// and notify the subscribers
+	if len(changes.notify) > 0 && g.GobServer != nil && g.FlagConfig.WatchTemplates {
+		g.GobServer.NotifySubscribers(changes.notify)
+	}
+
+	// Browsers are reloaded once a restarted application is ready (see Run),
+	// otherwise they only need to be told when a page or its styles changed
+	if len(changes.rebuild) == 0 && len(changes.restart) == 0 {
+		if len(changes.notify) > 0 || len(changes.reload) > 0 {
+			g.reloadBrowsers()
+		} else if len(changes.css) > 0 {
+			g.refreshCSS(changes.css)
+		}
+	}
 }
 
-// getChangeType looks at the files that were modified and checks their extension
-func (g *Gob) getChangeType(fileChanges []string) (app bool, views []string) {
-	for _, filename := range fileChanges {
-		fileExt := filepath.Ext(filename)
-
-		// For now, just hardcode since we only have one of each type
-		// TODO(billy) handle a slice better
-		switch fileExt {
-		case g.Config.BuildTypes[0]:
-			app = true
-		case g.Config.TemplateTypes[0]:
-			views = append(views, filename)
+// watchTree recursively adds watchers to root and all of its sub directories,
+// skipping hidden and ignored ones. It returns the files found along the way
+func (g *Gob) watchTree(watcher fileWatcher, root string) ([]string, error) {
+	var files []string
+	f := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Only drop watchers in directories
+		if !info.IsDir() {
+			files = append(files, path)
+			return nil
+		}
+
+		// Ignore hidden and excluded directories
+		if strings.HasPrefix(filepath.Base(path), ".") || g.filter.ignored(path, true) {
+			return filepath.SkipDir
+		}
+
+		// The rules of a .gitignore apply to everything below it
+		g.filter.addGitignore(path)
+
+		g.watchedMu.Lock()
+		defer g.watchedMu.Unlock()
+		if g.watched[path] {
+			return nil
 		}
+		if err := watcher.Watch(path); err != nil {
+			return err
+		}
+		g.watched[path] = true
+
+		return nil
 	}
 
-	return
+	err := filepath.Walk(root, f)
+	return files, err
 }
 
-func purgeChanges(w *fsnotify.Watcher) []string {
-	var changes []string
-	moreChanges := true
-	for moreChanges {
-		time.Sleep(time.Millisecond * 300)
-		select {
-		case ev := <-w.Event:
-			changes = append(changes, ev.Name)
-		default:
-			// for some reason fsnotify sends multiple events
-			// for the same file but the channel isn't buffered with them?
-			moreChanges = false
+// unwatchTree removes the watchers of dir and all of its sub directories
+func (g *Gob) unwatchTree(watcher fileWatcher, dir string) {
+	g.watchedMu.Lock()
+	defer g.watchedMu.Unlock()
+
+	for path := range g.watched {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			// The watch is usually gone already along with the directory
+			watcher.RemoveWatch(path)
+			delete(g.watched, path)
+		}
+	}
+}
+
+// handleDirEvent starts watching directories that are created inside our packages
+// and stops watching the ones that are removed. It returns the files that
+// were found in a newly created directory
+func (g *Gob) handleDirEvent(watcher fileWatcher, ev fileEvent) []string {
+	switch {
+	case ev.IsCreate():
+		info, err := os.Stat(ev.Name)
+		if err != nil || !info.IsDir() || !g.inWatchRoots(ev.Name) {
+			return nil
+		}
+
+		// Hidden and excluded directories don't get a watcher (see watchTree)
+		if strings.HasPrefix(filepath.Base(ev.Name), ".") || g.filter.ignored(ev.Name, true) {
+			return nil
+		}
+
+		g.Print("watching new directory " + ev.Name)
+		files, err := g.watchTree(watcher, ev.Name)
+		if err != nil {
+			g.PrintErr(err)
 		}
+		return files
+	case ev.IsDelete(), ev.IsRename():
+		g.unwatchTree(watcher, ev.Name)
 	}
 
-	return changes
+	return nil
+}
+
+// inWatchRoots reports whether path is inside one of the recursively watched packages
+func (g *Gob) inWatchRoots(path string) bool {
+	for _, root := range g.watchRoots {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
 }
 
+// restartApp rebuilds the application and restarts it. The application
+// keeps running until the new binary has been built successfully
 func (g *Gob) restartApp() {
+	g.Print("rebuilding application...")
+	if !g.Build() {
+		g.keepRunning()
+		return
+	}
+
 	g.Print("restarting application...")
-	if g.Cmd != nil {
-		g.Cmd.Process.Kill()
-		g.Cmd.Process.Wait()
-		g.Cmd.Process.Release()
+	g.proxy.hold()
+	g.stopApp()
+	g.Run()
+}
+
+// keepRunning lets the user know the last good build is
+// still running after a build failed
+func (g *Gob) keepRunning() {
+	if g.running() {
+		g.Print("the last successful build keeps running")
 	}
-	build := g.Build()
-	if build {
+}
+
+// watchWorldImports watches the directories of the packages inside the module
+// that the World packages import (e.g. an "internal/lib" shared by several
+// binaries), so that changing them rebuilds the packages depending on them
+func (g *Gob) watchWorldImports() {
+	if g.worldGraph == nil || g.ModuleRoot == "" {
+		return
+	}
+
+	var added []string
+	for _, dir := range g.worldGraph.Dirs() {
+		rel, err := filepath.Rel(g.ModuleRoot, dir)
+		if err != nil || strings.HasPrefix(rel, "..") || g.inWatchRoots(dir) || g.filter.ignored(dir, true) {
+			continue
+		}
+
+		g.watchedMu.Lock()
+		watched := g.watched[dir]
+		g.watchedMu.Unlock()
+		if watched {
+			continue
+		}
+
+		if err := g.watcher.Watch(dir); err != nil {
+			g.PrintErr(err)
+			continue
+		}
+		g.watchedMu.Lock()
+		g.watched[dir] = true
+		g.watchedMu.Unlock()
+		added = append(added, dir)
+	}
+
+	if len(added) == 0 {
+		return
+	}
+
+	// The patterns apply to the new directories too
+	g.ruleRoots = append(g.ruleRoots, added...)
+	g.filter.addRoots(added, g.FlagConfig.Exclude, g.FlagConfig.Include)
+	rules, err := g.compileRules(g.ruleRoots)
+	if err != nil {
+		g.PrintErr(err)
+		return
+	}
+	g.rules = rules
+}
+
+// restartChanged restarts the application after the given source files changed.
+// When running a World, only the packages affected by the change are restarted
+func (g *Gob) restartChanged(files []string) {
+	if g.worldGraph == nil {
+		g.restartApp()
+		return
+	}
+
+	var dirs []string
+	for _, file := range files {
+		dirs = append(dirs, filepath.Dir(file))
+	}
+
+	// The change may have added or removed imports, in which
+	// case the imports have to be resolved again
+	if g.worldGraph.ImportsChanged(dirs) {
+		g.worldGraph.Refresh()
+		g.watchWorldImports()
+	}
+
+	// Changes we can't trace back to a World package (e.g. a brand new package)
+	// rebuild everything, just like before
+	pkgs := g.worldGraph.Affected(dirs)
+	if len(pkgs) == 0 {
+		g.restartApp()
+	} else {
+		g.restartPkgs(pkgs)
+	}
+}
+
+// restartOnly restarts the application from the binaries that were
+// already built, without rebuilding them. When running a World, only the
+// packages that declare the changed files in their restart files are restarted
+func (g *Gob) restartOnly(files []string) {
+	if g.FlagConfig.NoRunMode {
+		return
+	}
+
+	g.proxy.hold()
+	defer g.proxy.release()
+
+	if len(g.World) == 0 {
+		g.Print("restarting application without rebuilding...")
+		g.stopApp()
 		g.Run()
+		return
+	}
+
+	pkgs := g.restartPkgsFor(files)
+	g.Print("restarting " + strings.Join(pkgs, ", ") + " without rebuilding...")
+	g.stopPkgs(pkgs)
+	if g.runHooks("pre-run", g.FlagConfig.Hooks.PreRun) {
+		g.runWorld(pkgs)
+	}
+}
+
+// restartPkgs rebuilds and restarts only the given World packages. Their
+// processes keep running until the new binaries have been built successfully
+func (g *Gob) restartPkgs(pkgs []string) {
+	g.Print("rebuilding " + strings.Join(pkgs, ", ") + "...")
+	if !g.buildPkgs(pkgs) {
+		g.keepRunning()
+		return
+	}
+
+	if g.FlagConfig.NoRunMode {
+		g.Print("waiting for changes to recompile...")
+		return
+	}
+
+	g.Print("restarting " + strings.Join(pkgs, ", ") + "...")
+	g.proxy.hold()
+	defer g.proxy.release()
+	g.stopPkgs(pkgs)
+	if g.runHooks("pre-run", g.FlagConfig.Hooks.PreRun) {
+		g.runWorld(pkgs)
 	}
 }
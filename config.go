@@ -4,10 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"go/build"
 	"io"
 	"io/ioutil"
 	"os"
-	"path"
+	"path/filepath"
 )
 
 // Config are all the basic configuration options
@@ -18,6 +19,7 @@ type Config struct {
 
 	BuildTypes    []string // File extensions that cause the app to rebuild
 	TemplateTypes []string // File extensions that cause the templating engine to re-render
+	RestartTypes  []string // File extensions that restart the app without rebuilding it (e.g. config files)
 	IgnoreTypes   []string // File extensions to let the filewatcher ignore
 
 	Stdout io.Writer
@@ -28,6 +30,10 @@ type Config struct {
 // configuration options used for setting up our application
 func DefaultConfig() *Config {
 	goPath := os.Getenv("GOPATH")
+	if goPath == "" {
+		// Go has a default GOPATH when it isn't set (e.g. when only using modules)
+		goPath = filepath.SplitList(build.Default.GOPATH)[0]
+	}
 
 	return &Config{
 		GoPath:   goPath,
@@ -39,6 +45,7 @@ func DefaultConfig() *Config {
 
 		BuildTypes:    []string{".go"},
 		TemplateTypes: []string{".soy"},
+		RestartTypes:  []string{".env", ".toml", ".yaml", ".yml"},
 		IgnoreTypes:   []string{".js", ".css", ".scss", ".png", ".jpg", ".gif"},
 	}
 }
@@ -46,12 +53,47 @@ func DefaultConfig() *Config {
 // GobFlags represents the options gob uses when building and watching
 // the target package. These are specified in the CLI
 type GobFlags struct {
-	NoRunMode                    bool   `json:"noRunMode"`                   // Listen and hot compile code, but don't run the program
-	WatchTemplates               bool   `json:"watchTemplates"`              // whether or not to watch templates and notify subscribed gob agents
-	GobServerPort                string `json:"gobServerPort"`               // what port to run the GobServer on (where GobClients can register)
-	WatchPkgDependencies         bool   `json:"watchPackageDependencies"`    // whether or not to watch dependencies of the target package
-	DependencyCheckInterval      int    `json:"dependencyCheckInterval"`     // the interval to sue when monitoring dependencies
-	RecursivelyWatchDependencies bool   `json:recursivelyWatchDependencies"` // whether or not to watch dependencies recursively
+	NoRunMode                    bool     `json:"noRunMode"`                    // Listen and hot compile code, but don't run the program
+	WatchTemplates               bool     `json:"watchTemplates"`               // whether or not to watch templates and notify subscribed gob agents
+	GobServerPort                string   `json:"gobServerPort"`                // what port to run the GobServer on (where GobClients can register)
+	WatchPkgDependencies         bool     `json:"watchPackageDependencies"`     // whether or not to watch dependencies of the target package
+	DependencyCheckInterval      int      `json:"dependencyCheckInterval"`      // the interval to sue when monitoring dependencies
+	RecursivelyWatchDependencies bool     `json:"recursivelyWatchDependencies"` // whether or not to watch dependencies recursively
+	StopSignal                   string   `json:"stopSignal"`                   // the signal sent to the application to ask it to shut down
+	StopTimeout                  int      `json:"stopTimeout"`                  // milliseconds to wait after StopSignal before killing the application
+	BuildJobs                    int      `json:"buildJobs"`                    // how many World packages to build at the same time (0 uses the number of CPUs)
+	Include                      []string `json:"include"`                      // gitignore-style patterns of the only files to watch (empty watches everything)
+	Exclude                      []string `json:"exclude"`                      // gitignore-style patterns of files and directories to ignore
+	Debounce                     int      `json:"debounce"`                     // milliseconds without changes to wait for before rebuilding
+	Poll                         bool     `json:"poll"`                         // poll the filesystem for changes instead of using fsnotify
+	PollInterval                 int      `json:"pollInterval"`                 // milliseconds between polls
+	PollHash                     bool     `json:"pollHash"`                     // also compare file contents when polling (for filesystems with coarse mtimes)
+	TestMode                     bool     `json:"testMode"`                     // run `go test` for the packages affected by a change
+	TestRun                      string   `json:"testRun"`                      // only run the tests matching this pattern (like `go test -run`)
+	Hooks                        Hooks    `json:"hooks"`                        // commands to run before/after building and before running (see "hooks.go")
+	Rules                        []Rule   `json:"rules"`                        // what to do when files matching a pattern change (see "rules.go")
+	RestartFiles                 []string `json:"restartFiles"`                 // patterns of files that restart the app without rebuilding it
+	RestartOnCrash               bool     `json:"restartOnCrash"`               // restart the application when it exits with an error
+	MaxRestarts                  int      `json:"maxRestarts"`                  // give up after this many crashes in a row (0 never gives up)
+	RestartBackoff               int      `json:"restartBackoff"`               // milliseconds to wait before the first restart, doubled after every crash
+	ForwardSignals               []string `json:"forwardSignals"`               // signals gob passes on to the application (e.g. "SIGHUP", "SIGUSR1")
+	AttachStdin                  bool     `json:"attachStdin"`                  // connect gob's stdin to the application
+
+	Ready       *Readiness `json:"ready"`       // how to tell the application has started up (see "ready.go")
+	Proxy       string     `json:"proxy"`       // the address of a proxy to the application that holds requests while it restarts (see "proxy.go")
+	ProxyTarget string     `json:"proxyTarget"` // the address the application listens on (defaults to the tcp address of Ready)
+	LiveReload  bool       `json:"liveReload"`  // reload the browsers connected to the GobServer after changes (see "livereload.go")
+
+	BuildOptions // extra flags, tags, ldflags and environment for go build (see "world.go")
+}
+
+// configDir returns the directory of the gob config file: the directory of the
+// target package, or the module root when building a World inside a module
+func (gb *Gob) configDir() string {
+	if gb.PackagePath == "" && gb.ModuleRoot != "" {
+		return gb.ModuleRoot
+	}
+	return gb.pkgDir(gb.PackagePath)
 }
 
 // WriteConfigToPackage writes a gob config file to the directory of the target package
@@ -69,7 +111,7 @@ func (gb *Gob) WriteConfigToPackage() {
 		return
 	}
 
-	err = ioutil.WriteFile(path.Join(gb.Config.SrcDir, gb.PackagePath, ".gob.json"),
+	err = ioutil.WriteFile(filepath.Join(gb.configDir(), ".gob.json"),
 		buffer.Bytes(),
 		0644)
 	if err != nil {
@@ -79,7 +121,7 @@ func (gb *Gob) WriteConfigToPackage() {
 
 // LoadConfig loads the data from the gob config file into the passed GobFlags struct
 func (gb *Gob) LoadConfig() {
-	data, err := ioutil.ReadFile(path.Join(gb.Config.SrcDir, gb.PackagePath, ".gob.json"))
+	data, err := ioutil.ReadFile(filepath.Join(gb.configDir(), ".gob.json"))
 	if err != nil {
 		fmt.Printf("[gob] failed to load config: %v\n", err)
 		return
@@ -0,0 +1,130 @@
+package gob
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// consoleHelp lists the commands that can be typed into gob's stdin
+const consoleHelp = `commands:
+  r, rs, restart   rebuild and restart the application
+  b, rebuild       rebuild without restarting
+  t, test          run the tests of the application
+  s, status        show the running processes, last build and watched directories
+  d, deps          list the watched dependencies
+  q, quit          stop the application and exit
+  h, help          show this help`
+
+// readCommands passes the commands read from stdin, one per line, on to
+// the Watch loop. Nothing is read when stdin is attached to the application
+func (g *Gob) readCommands() {
+	if g.FlagConfig.AttachStdin {
+		return
+	}
+
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if command := strings.TrimSpace(scanner.Text()); command != "" {
+				g.commands <- command
+			}
+		}
+
+		// stdin was closed (e.g. gob is running in the background),
+		// a double Ctrl-C can still send commands
+	}()
+
+	g.Print(`type "h" and enter for a list of commands`)
+}
+
+// runConsoleCommand runs a command typed into gob's stdin
+func (g *Gob) runConsoleCommand(command string) {
+	switch strings.ToLower(command) {
+	case "r", "rs", "restart":
+		g.restartApp()
+	case "b", "rebuild", "build":
+		g.Build()
+	case "t", "test":
+		g.Test(g.RootPkgs())
+	case "s", "status":
+		g.printStatus()
+	case "d", "deps":
+		g.printDeps()
+	case "q", "quit", "exit":
+		g.Print("exiting...")
+		g.exit(0)
+	case "h", "help":
+		g.Print(consoleHelp)
+	default:
+		g.Print(fmt.Sprintf("unknown command %q, type \"h\" for a list of commands", command))
+	}
+}
+
+// printStatus prints the running processes, when the last successful
+// build happened and which directories are being watched
+func (g *Gob) printStatus() {
+	g.procsMu.Lock()
+	var procs []*Process
+	if g.Cmd != nil {
+		procs = append(procs, g.Cmd)
+	}
+	pkgs := make([]string, 0, len(g.Procs))
+	for pkg := range g.Procs {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		procs = append(procs, g.Procs[pkg])
+	}
+	g.procsMu.Unlock()
+
+	g.Print("status:")
+	if len(procs) == 0 {
+		fmt.Println("[gob]   nothing is running")
+	}
+	for _, p := range procs {
+		select {
+		case <-p.done:
+			fmt.Printf("[gob]   pid %-7d %s (exited)\n", p.Process.Pid, p.name())
+		default:
+			uptime := time.Since(p.started) / time.Second * time.Second
+			fmt.Printf("[gob]   pid %-7d %s (up %v)\n", p.Process.Pid, p.name(), uptime)
+		}
+	}
+
+	if g.lastBuild.IsZero() {
+		fmt.Println("[gob]   last build: none succeeded yet")
+	} else {
+		fmt.Printf("[gob]   last build: %s\n", g.lastBuild.Format("15:04:05"))
+	}
+
+	g.watchedMu.Lock()
+	dirs := make([]string, 0, len(g.watched))
+	for dir := range g.watched {
+		dirs = append(dirs, dir)
+	}
+	g.watchedMu.Unlock()
+	sort.Strings(dirs)
+
+	fmt.Printf("[gob]   watching %d directories:\n", len(dirs))
+	for _, dir := range dirs {
+		fmt.Println("[gob]     " + dir)
+	}
+}
+
+// printDeps prints the dependencies that are being watched
+func (g *Gob) printDeps() {
+	if len(g.PkgDeps) == 0 {
+		g.Print("no dependencies are being watched (see -deps)")
+		return
+	}
+
+	g.Print("watched dependencies:")
+	for _, dep := range g.PkgDeps {
+		fmt.Println("[gob]   " + dep)
+	}
+}
@@ -0,0 +1,78 @@
+package gob
+
+import (
+	"sync"
+	"time"
+)
+
+// debouncer collects changed files and hands them off as a single batch once
+// no new changes have come in for the quiet period (a trailing-edge debounce).
+// Adding files never blocks, so the watcher can keep draining its events
+// while a batch is being built
+type debouncer struct {
+	quiet time.Duration
+	ready chan struct{} // receives a value when a batch can be taken
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	files   []string // changes still inside the quiet period
+	pending []string // changes whose quiet period is over
+}
+
+// newDebouncer returns a debouncer that waits for the given quiet period
+func newDebouncer(quiet time.Duration) *debouncer {
+	return &debouncer{
+		quiet: quiet,
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// add records changed files and restarts the quiet period
+func (d *debouncer) add(files ...string) {
+	if len(files) == 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.files = append(d.files, files...)
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.quiet, d.flush)
+	} else {
+		d.timer.Reset(d.quiet)
+	}
+}
+
+// flush moves the buffered files to the pending batch and signals ready
+func (d *debouncer) flush() {
+	d.mu.Lock()
+	d.pending = append(d.pending, d.files...)
+	d.files = nil
+	d.mu.Unlock()
+
+	select {
+	case d.ready <- struct{}{}:
+	default:
+		// The last signal hasn't been picked up yet,
+		// it'll take these files along with it
+	}
+}
+
+// take returns the unique files of the pending batch and empties it
+func (d *debouncer) take() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, file := range d.pending {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	d.pending = nil
+
+	return files
+}
@@ -0,0 +1,85 @@
+package gob
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testQuiet = 50 * time.Millisecond
+
+func TestDebouncerBatchesBurst(t *testing.T) {
+	d := newDebouncer(testQuiet)
+
+	var last time.Time
+	for _, file := range []string{"a.go", "b.go", "c.go"} {
+		d.add(file)
+		last = time.Now()
+		time.Sleep(testQuiet / 5)
+	}
+
+	select {
+	case <-d.ready:
+	case <-time.After(10 * testQuiet):
+		t.Fatal("the batch never became ready")
+	}
+	if waited := time.Since(last); waited < testQuiet {
+		t.Errorf("batch was ready %v after the last add, want at least %v", waited, testQuiet)
+	}
+
+	want := []string{"a.go", "b.go", "c.go"}
+	if files := d.take(); !reflect.DeepEqual(files, want) {
+		t.Errorf("take() = %v, want %v", files, want)
+	}
+
+	// The whole burst is a single batch
+	select {
+	case <-d.ready:
+		t.Errorf("got a second batch: %v", d.take())
+	case <-time.After(2 * testQuiet):
+	}
+}
+
+func TestDebouncerTake(t *testing.T) {
+	d := newDebouncer(testQuiet)
+	d.add("a.go", "b.go", "a.go")
+	d.add("b.go", "c.go")
+	<-d.ready
+
+	want := []string{"a.go", "b.go", "c.go"}
+	if files := d.take(); !reflect.DeepEqual(files, want) {
+		t.Errorf("take() = %v, want %v", files, want)
+	}
+	if files := d.take(); len(files) != 0 {
+		t.Errorf("take() after taking the batch = %v, want nothing", files)
+	}
+}
+
+func TestDebouncerAddDoesNotBlock(t *testing.T) {
+	d := newDebouncer(time.Millisecond)
+	d.add("a.go")
+	time.Sleep(10 * time.Millisecond)
+
+	// Nobody is taking the batch, adding has to keep working regardless
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			d.add("b.go")
+			time.Sleep(time.Millisecond / 10)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("add blocked while a batch was waiting to be taken")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	<-d.ready
+	want := []string{"a.go", "b.go"}
+	if files := d.take(); !reflect.DeepEqual(files, want) {
+		t.Errorf("take() = %v, want %v", files, want)
+	}
+}
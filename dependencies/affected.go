@@ -0,0 +1,185 @@
+package dependencies
+
+import (
+	"go/build"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Affected returns the packages in Pkgs whose source lives in, or which import
+// (directly or transitively), any of the given directories
+func (d *Graph) Affected(dirs []string) []string {
+	if d.dirRoots == nil {
+		d.resolveImports()
+	}
+
+	affected := make(map[string]bool)
+	for _, dir := range dirs {
+		for root := range d.dirRoots[filepath.Clean(dir)] {
+			affected[root] = true
+		}
+	}
+
+	// Keep the order the packages were given in
+	var pkgs []string
+	for _, pkg := range d.Pkgs {
+		if affected[pkg] {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+
+	return pkgs
+}
+
+// Dirs returns the directories of the packages in Pkgs and
+// of every package they import (directly or transitively)
+func (d *Graph) Dirs() []string {
+	if d.dirRoots == nil {
+		d.resolveImports()
+	}
+
+	dirs := make([]string, 0, len(d.dirRoots))
+	for dir := range d.dirRoots {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	return dirs
+}
+
+// Refresh forgets the resolved imports so they are looked up again
+// the next time they're needed (e.g. after imports were added or removed)
+func (d *Graph) Refresh() {
+	d.dirRoots = nil
+	d.dirImports = nil
+}
+
+// ImportsChanged reports whether the imports of any of the resolved packages
+// in the given directories changed since they were resolved. Reading the
+// imports of a directory is cheap, unlike resolving the whole graph again
+func (d *Graph) ImportsChanged(dirs []string) bool {
+	if d.dirImports == nil {
+		// Nothing has been resolved yet
+		return false
+	}
+
+	for _, dir := range dirs {
+		before, ok := d.dirImports[filepath.Clean(dir)]
+		if !ok {
+			// Nothing depends on this directory
+			continue
+		}
+
+		pkg, err := d.config.ImportDir(dir, 0)
+		if err != nil {
+			// e.g. the package was removed or its imports can't be parsed
+			return true
+		}
+		if !equalImports(before, pkgImports(pkg)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pkgImports returns all of the imports of a package, including the ones of its tests
+func pkgImports(pkg *build.Package) []string {
+	imports := append(append(append([]string(nil), pkg.Imports...), pkg.TestImports...), pkg.XTestImports...)
+	sort.Strings(imports)
+	return imports
+}
+
+func equalImports(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveImports walks the full import tree of every package in Pkgs and
+// records, for each package directory found, which of the root packages depend on it
+func (d *Graph) resolveImports() {
+	config, srcDir, mode := d.buildContext()
+	r := &importResolver{
+		config: config,
+		mode:   mode,
+		pkgs:   make(map[string]*build.Package),
+	}
+
+	d.config = config
+	d.dirRoots = make(map[string]map[string]bool)
+	d.dirImports = make(map[string][]string)
+	for _, root := range d.Pkgs {
+		seen := make(map[string]bool)
+		d.walkImports(r, root, root, srcDir, seen)
+	}
+}
+
+// importResolver imports packages, remembering the results since the same
+// dependencies show up under many of the root packages
+type importResolver struct {
+	config *build.Context
+	mode   build.ImportMode
+	pkgs   map[string]*build.Package // nil means the package couldn't be imported
+}
+
+func (r *importResolver) importPkg(path, srcDir string) *build.Package {
+	// Relative imports depend on the directory they're imported from
+	key := path
+	if build.IsLocalImport(path) {
+		key = filepath.Join(srcDir, path)
+	}
+
+	if pkg, ok := r.pkgs[key]; ok {
+		return pkg
+	}
+
+	pkg, err := r.config.Import(path, srcDir, r.mode)
+	if err != nil && (pkg == nil || pkg.Dir == "") {
+		pkg = nil
+	}
+	r.pkgs[key] = pkg
+
+	return pkg
+}
+
+func (d *Graph) walkImports(r *importResolver, root, path, srcDir string, seen map[string]bool) {
+	if seen[path] {
+		return
+	}
+	seen[path] = true
+
+	// Ignore the GO standard library imports
+	if _, ok := stdlib[strings.Split(path, "/")[0]]; ok && !d.StdLib {
+		return
+	}
+
+	pkg := r.importPkg(path, srcDir)
+	if pkg == nil || pkg.Dir == "" || (pkg.Goroot && !d.StdLib) {
+		return
+	}
+
+	dir := filepath.Clean(pkg.Dir)
+	if d.dirRoots[dir] == nil {
+		d.dirRoots[dir] = make(map[string]bool)
+		d.dirImports[dir] = pkgImports(pkg)
+	}
+	d.dirRoots[dir][root] = true
+
+	imports := pkg.Imports
+	if d.Tests && path == root {
+		// Only the tests of the root packages matter, not the ones of their dependencies
+		imports = append(append(imports, pkg.TestImports...), pkg.XTestImports...)
+	}
+
+	for _, imp := range imports {
+		d.walkImports(r, root, imp, pkg.Dir, seen)
+	}
+}
@@ -11,12 +11,18 @@ import (
 type Graph struct {
 	StdLib bool     // the value 'false' will ignore stdlib imports
 	SrcDir string   // the root src directory of all the packages
+	Dir    string   // the module root used to resolve imports (empty means GOPATH mode)
 	Pkgs   []string // list of packages to use when building our depdendency tree
+	Tests  bool     // 'true' also follows the imports of the test files of Pkgs (see Affected)
 
 	TotalDeps int // total number of dependencies used across all packages
 
 	RootNode *Node
 	Nodes    map[string]*Node // map of all the dependencies across all our projects
+
+	dirRoots   map[string]map[string]bool // package directory -> the packages in Pkgs that depend on it
+	dirImports map[string][]string        // package directory -> the imports it had when it was resolved
+	config     *build.Context             // the build context the imports were resolved with
 }
 
 // NewGraph is used to build out a dependency tree, and provide useful helpers
@@ -26,7 +32,9 @@ func NewGraph(d *Graph) *Graph {
 	Graph := Graph{
 		StdLib: d.StdLib,
 		SrcDir: d.SrcDir,
+		Dir:    d.Dir,
 		Pkgs:   d.Pkgs,
+		Tests:  d.Tests,
 
 		RootNode: &Node{
 			Path: d.SrcDir,
@@ -90,14 +98,30 @@ func (n *Node) print(prefix string) {
 	}
 }
 
+// buildContext returns the build context, source directory and import mode
+// used to resolve the imports of our packages
+func (d *Graph) buildContext() (*build.Context, string, build.ImportMode) {
+	config := build.Default
+
+	// In module mode, imports are resolved through the module graph
+	// from the module root instead of the GOPATH src directory.
+	// go/build only consults the go command when binary-only packages aren't allowed
+	if d.Dir != "" {
+		config.Dir = d.Dir
+		return &config, d.Dir, 0
+	}
+
+	return &config, d.SrcDir, build.AllowBinary
+}
+
 // buildTree iterates through a list of packages to figure out all the unique
 // imports and builds a dependency graph based on what it finds
 func (d *Graph) buildTree() {
-	config := build.Default
+	config, srcDir, mode := d.buildContext()
 
 	// For each package, look for the dependencies and build out a tree
 	for p := range d.Pkgs {
-		pkg, _ := config.Import(d.Pkgs[p], d.SrcDir, build.AllowBinary)
+		pkg, _ := config.Import(d.Pkgs[p], srcDir, mode)
 		imports := pkg.Imports
 
 		// Iterate through the imports and build our tree
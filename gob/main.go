@@ -4,6 +4,7 @@ import (
 	"flag"
 	"github.com/b1lly/gob"
 	"github.com/b1lly/gob/agent"
+	"strings"
 )
 
 const (
@@ -18,6 +19,27 @@ var (
 	watchDeps            = flag.Bool("deps", false, "watch dependencies of your package for changes")
 	depInterval          = flag.Int("intvl", 1, "time between dependency checks")
 	recursivelyWatchDeps = flag.Bool("recWatch", true, "recursively watch dependencies")
+	stopSignal           = flag.String("stopSignal", "SIGTERM", "signal sent to the application to ask it to shut down")
+	stopTimeout          = flag.Int("stopTimeout", 5000, "milliseconds to wait for the application to shut down before killing it")
+	buildJobs            = flag.Int("j", 0, "number of World packages to build in parallel (0 uses the number of CPUs)")
+	buildArgs            = flag.String("buildArgs", "", "extra space separated arguments for go build (e.g. \"-race -v\")")
+	buildTags            = flag.String("tags", "", "comma separated build tags for go build")
+	ldflags              = flag.String("ldflags", "", "flags passed to go build with -ldflags")
+	buildEnv             = flag.String("buildEnv", "", "comma separated KEY=VALUE pairs added to the environment of go build")
+	debounce             = flag.Int("debounce", 300, "milliseconds without changes to wait for before rebuilding")
+	poll                 = flag.Bool("poll", false, "poll the filesystem for changes instead of relying on fsnotify (e.g. NFS or Vagrant)")
+	pollInterval         = flag.Int("pollInterval", 1000, "milliseconds between filesystem polls")
+	pollHash             = flag.Bool("pollHash", false, "also compare the contents of files when polling")
+	testMode             = flag.Bool("test", false, "run go test for the packages affected by each change")
+	testRun              = flag.String("run", "", "only run the tests matching this pattern in test mode")
+	restartOnCrash       = flag.Bool("restartOnCrash", false, "restart the application when it crashes")
+	maxRestarts          = flag.Int("maxRestarts", 5, "give up restarting after this many crashes in a row (0 never gives up)")
+	restartBackoff       = flag.Int("restartBackoff", 500, "milliseconds to wait before restarting a crashed application, doubled after every crash")
+	forwardSignals       = flag.String("forwardSignals", "", "comma separated signals to pass on to the application (e.g. HUP,USR1)")
+	attachStdin          = flag.Bool("stdin", false, "connect gob's stdin to the application")
+	proxy                = flag.String("proxy", "", "address of a proxy to the application that holds requests while it restarts (e.g. :3000)")
+	proxyTarget          = flag.String("proxyTarget", "", "address the application listens on, for the proxy (e.g. localhost:8080)")
+	liveReload           = flag.Bool("livereload", false, "reload the browsers connected to the GobServer after changes")
 	version              = flag.Bool("version", false, "print gob's version and exit")
 
 	// flags pertaining to gob config file usage
@@ -37,6 +59,29 @@ func main() {
 		WatchPkgDependencies:         *watchDeps,
 		DependencyCheckInterval:      *depInterval,
 		RecursivelyWatchDependencies: *recursivelyWatchDeps,
+		StopSignal:                   *stopSignal,
+		StopTimeout:                  *stopTimeout,
+		BuildJobs:                    *buildJobs,
+		Debounce:                     *debounce,
+		Poll:                         *poll,
+		PollInterval:                 *pollInterval,
+		PollHash:                     *pollHash,
+		TestMode:                     *testMode,
+		TestRun:                      *testRun,
+		RestartOnCrash:               *restartOnCrash,
+		MaxRestarts:                  *maxRestarts,
+		RestartBackoff:               *restartBackoff,
+		ForwardSignals:               splitList(*forwardSignals),
+		AttachStdin:                  *attachStdin,
+		Proxy:                        *proxy,
+		ProxyTarget:                  *proxyTarget,
+		LiveReload:                   *liveReload,
+		BuildOptions: gob.BuildOptions{
+			BuildArgs: strings.Fields(*buildArgs),
+			BuildTags: splitList(*buildTags),
+			LDFlags:   *ldflags,
+			BuildEnv:  splitList(*buildEnv),
+		},
 	})
 
 	if *version {
@@ -57,9 +102,10 @@ func main() {
 	}
 
 	// Decides whether or not to start up the GobServer
-	// for the GobAgent client to connect to
-	if *watchTemplates {
-		gb.GobServer = agent.NewGobServer(*port)
+	// for the GobAgent client (and live reloading browsers) to connect to.
+	// The port may have been changed by the config
+	if gb.FlagConfig.WatchTemplates || gb.FlagConfig.LiveReload {
+		gb.GobServer = agent.NewGobServer(gb.FlagConfig.GobServerPort)
 		go gb.GobServer.Start()
 	}
 
@@ -82,6 +128,22 @@ func main() {
 		gb.Run()
 	}
 
+	// In test mode, start off with the tests of the application
+	if gb.FlagConfig.TestMode {
+		gb.Test(gb.RootPkgs())
+	}
+
 	// Start watching the filesystem for updates
 	gb.Watch()
 }
+
+// splitList splits a comma separated flag value, ignoring empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
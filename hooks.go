@@ -0,0 +1,95 @@
+package gob
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Hook is a shell command gob runs at some point of a rebuild
+type Hook struct {
+	Command    string   `json:"command"`    // run with `sh -c`
+	Extensions []string `json:"extensions"` // only run when a file with one of these extensions changed (empty always runs)
+}
+
+// Hooks are the commands gob runs, in order, before a build, after a
+// successful build and before the application is started:
+//
+//	"hooks": {
+//	  "preBuild": [{"command": "protoc --go_out=. api.proto", "extensions": [".proto"]}],
+//	  "postBuild": [{"command": "cp -r assets build/"}],
+//	  "preRun": [{"command": "./migrate up"}]
+//	}
+type Hooks struct {
+	PreBuild  []Hook `json:"preBuild"`
+	PostBuild []Hook `json:"postBuild"`
+	PreRun    []Hook `json:"preRun"`
+}
+
+// extensions returns every file extension the hooks filter on
+func (h Hooks) extensions() []string {
+	var exts []string
+	for _, hooks := range [][]Hook{h.PreBuild, h.PostBuild, h.PreRun} {
+		for _, hook := range hooks {
+			exts = append(exts, hook.Extensions...)
+		}
+	}
+	return exts
+}
+
+// shouldRun reports whether the hook applies to the changed files.
+// Every hook runs for the initial build, when nothing has changed yet
+func (h Hook) shouldRun(changes []string) bool {
+	if len(h.Extensions) == 0 || changes == nil {
+		return true
+	}
+
+	for _, file := range changes {
+		ext := filepath.Ext(file)
+		for _, hookExt := range h.Extensions {
+			if ext == hookExt {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runHooks runs the hooks that apply to the current changes and stops at
+// the first one that fails. The changed files are passed to each command
+// through the GOB_CHANGED_FILES environment variable
+func (g *Gob) runHooks(stage string, hooks []Hook) bool {
+	for _, hook := range hooks {
+		if !hook.shouldRun(g.changes) {
+			continue
+		}
+
+		cmd := exec.Command("sh", "-c", hook.Command)
+		cmd.Dir = g.hookDir()
+		cmd.Env = append(os.Environ(), "GOB_CHANGED_FILES="+strings.Join(g.changes, " "))
+		cmd.Stdout = g.Config.Stdout
+		cmd.Stderr = g.Config.Stderr
+
+		g.Print("running " + stage + " hook... " + hook.Command)
+		if err := cmd.Run(); err != nil {
+			notifyFailed()
+			g.Print(stage + " hook failed: " + err.Error())
+			return false
+		}
+	}
+
+	return true
+}
+
+// hookDir returns the directory hooks are run in: the module root
+// or else the directory of the package we're building
+func (g *Gob) hookDir() string {
+	if g.ModuleRoot != "" {
+		return g.ModuleRoot
+	}
+	if g.PackagePath != "" {
+		return g.pkgDir(g.PackagePath)
+	}
+	return ""
+}
@@ -0,0 +1,262 @@
+package gob
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ignoreRule is a single gitignore-style pattern. Patterns are matched
+// against paths relative to base, the directory the pattern applies to
+type ignoreRule struct {
+	base     string
+	segments []string // the pattern split on "/" ("**" matches any number of directories)
+	negate   bool     // "!pattern" re-includes paths excluded by an earlier rule
+	dirOnly  bool     // "pattern/" only matches directories
+	anchored bool     // patterns containing a "/" only match relative to base
+}
+
+// newIgnoreRule parses a gitignore-style pattern. It returns false
+// for blank lines and comments
+func newIgnoreRule(base, pattern string) (*ignoreRule, bool) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return nil, false
+	}
+
+	r := &ignoreRule{base: base}
+	if strings.HasPrefix(pattern, "!") {
+		r.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if strings.Contains(pattern, "/") {
+		r.anchored = true
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+	if pattern == "" {
+		return nil, false
+	}
+
+	r.segments = strings.Split(pattern, "/")
+	return r, true
+}
+
+// match reports whether the rule matches the given absolute path
+func (r *ignoreRule) match(absPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	rel, err := filepath.Rel(r.base, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if !r.anchored {
+		// Patterns without a slash match the name at any depth
+		ok, _ := path.Match(r.segments[0], parts[len(parts)-1])
+		return ok
+	}
+
+	return matchSegments(r.segments, parts)
+}
+
+// matchSegments matches path segments against pattern segments, where
+// a "**" segment matches zero or more directories
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+
+			for i := range parts {
+				if matchSegments(pattern, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+
+	return len(parts) == 0
+}
+
+// fileFilter decides which files and directories gob watches, based on
+// .gitignore files and the include/exclude patterns in GobFlags
+type fileFilter struct {
+	gitignores []*ignoreRule // rules read from .gitignore files
+	excludes   []*ignoreRule // a later rule overrides the earlier ones (including the .gitignore rules)
+	includes   []*ignoreRule // when set, only files matching one of these are watched
+
+	mu     sync.RWMutex    // .gitignore files are added while events are being filtered
+	loaded map[string]bool // directories whose .gitignore has been read
+}
+
+// newFileFilter creates a filter where the configured patterns
+// are relative to each of the given root directories
+func (g *Gob) newFileFilter(roots []string) *fileFilter {
+	f := &fileFilter{
+		loaded: make(map[string]bool),
+	}
+
+	f.addRoots(roots, g.FlagConfig.Exclude, g.FlagConfig.Include)
+	return f
+}
+
+// addRoots adds the exclude and include patterns relative to each of the roots
+func (f *fileFilter) addRoots(roots, exclude, include []string) {
+	for _, root := range roots {
+		f.mu.Lock()
+		f.excludes = append(f.excludes, parseIgnoreRules(root, exclude)...)
+		f.includes = append(f.includes, parseIgnoreRules(root, include)...)
+		f.mu.Unlock()
+
+		// The .gitignore files between the repository root and the package also apply.
+		// The ones inside the package are added as its directories are walked
+		for _, dir := range repoAncestors(root) {
+			f.addGitignore(dir)
+		}
+	}
+}
+
+// repoAncestors returns the directories from the root of the git repository
+// that contains dir down to the parent of dir. It returns nothing when dir
+// isn't inside a git repository
+func repoAncestors(dir string) []string {
+	var ancestors []string
+	for parent := filepath.Dir(dir); parent != dir; dir, parent = parent, filepath.Dir(parent) {
+		ancestors = append([]string{parent}, ancestors...)
+		if _, err := os.Stat(filepath.Join(parent, ".git")); err == nil {
+			return ancestors
+		}
+	}
+
+	// Either dir is the root of the repository or it isn't inside one
+	return nil
+}
+
+// addGitignore reads the rules from the .gitignore file in dir, if it has one
+func (f *fileFilter) addGitignore(dir string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.loaded[dir] {
+		return
+	}
+	f.loaded[dir] = true
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	f.gitignores = append(f.gitignores, parseIgnoreRules(dir, strings.Split(string(data), "\n"))...)
+}
+
+// parseIgnoreRules parses all of the patterns relative to base
+func parseIgnoreRules(base string, patterns []string) []*ignoreRule {
+	var rules []*ignoreRule
+	for _, pattern := range patterns {
+		if rule, ok := newIgnoreRule(base, pattern); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// ignored reports whether changes to the given path should be ignored
+func (f *fileFilter) ignored(absPath string, isDir bool) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	// Anything inside an excluded directory is excluded too
+	for dir := filepath.Dir(absPath); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if f.excluded(dir, true) {
+			return true
+		}
+	}
+
+	if f.excluded(absPath, isDir) {
+		return true
+	}
+
+	// Include patterns only apply to files
+	if isDir {
+		return false
+	}
+
+	// Editor swap and backup files never trigger anything. Other hidden
+	// files (e.g. ".env") are left to the rules
+	if isEditorFile(filepath.Base(absPath)) {
+		return true
+	}
+
+	if len(f.includes) == 0 {
+		return false
+	}
+	for _, rule := range f.includes {
+		if rule.match(absPath, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// editorFiles match the swap, lock and backup files editors write
+// next to the files being edited
+var editorFiles = []string{".*.sw?", ".#*", "#*#", "*~", ".DS_Store"}
+
+// isEditorFile reports whether the file name is one of the editorFiles
+func isEditorFile(name string) bool {
+	for _, pattern := range editorFiles {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fileFilter) excluded(absPath string, isDir bool) bool {
+	excluded := false
+	for _, rule := range f.gitignores {
+		if rule.match(absPath, isDir) {
+			excluded = !rule.negate
+		}
+	}
+	for _, rule := range f.excludes {
+		if rule.match(absPath, isDir) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
+
+// filterChanges drops the changed files that should be ignored
+func (f *fileFilter) filterChanges(files []string) []string {
+	var changes []string
+	for _, file := range files {
+		info, err := os.Stat(file)
+		isDir := err == nil && info.IsDir()
+		if !f.ignored(file, isDir) {
+			changes = append(changes, file)
+		}
+	}
+	return changes
+}
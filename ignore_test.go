@@ -0,0 +1,39 @@
+package gob
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFilterKeepsHiddenConfigFiles(t *testing.T) {
+	root := t.TempDir()
+	g := &Gob{Config: DefaultConfig(), FlagConfig: &GobFlags{}}
+	f := g.newFileFilter([]string{root})
+
+	var files []string
+	for _, name := range []string{".env", "app.yaml", ".main.go.swp", ".#main.go", "main.go~", ".DS_Store"} {
+		files = append(files, filepath.Join(root, name))
+	}
+
+	want := files[:2]
+	if got := f.filterChanges(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("filterChanges() = %v, want %v", got, want)
+	}
+}
+
+func TestHiddenConfigFilesRestart(t *testing.T) {
+	root := t.TempDir()
+	g := &Gob{Config: DefaultConfig(), FlagConfig: &GobFlags{}}
+	rules, err := g.compileRules([]string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.rules = rules
+
+	env := filepath.Join(root, "api", ".env")
+	changes := g.classifyChanges(g.newFileFilter([]string{root}).filterChanges([]string{env}))
+	if want := []string{env}; !reflect.DeepEqual(changes.restart, want) {
+		t.Errorf("restart = %v, want %v", changes.restart, want)
+	}
+}
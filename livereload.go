@@ -0,0 +1,36 @@
+package gob
+
+import (
+	"net"
+	"net/http"
+)
+
+// reloadBrowsers tells the browsers connected for live reload to reload the page
+func (g *Gob) reloadBrowsers() {
+	if g.FlagConfig.LiveReload && g.GobServer != nil {
+		g.GobServer.Reload()
+	}
+}
+
+// refreshCSS tells the browsers connected for live reload
+// to reload their stylesheets
+func (g *Gob) refreshCSS(files []string) {
+	if g.FlagConfig.LiveReload && g.GobServer != nil {
+		g.GobServer.RefreshCSS(files)
+	}
+}
+
+// liveReloadScript returns the URL of the live reload script for a page
+// requested through the proxy, or "" when live reload is off
+func (g *Gob) liveReloadScript(req *http.Request) string {
+	if !g.FlagConfig.LiveReload {
+		return ""
+	}
+
+	// The GobServer runs on the same host as the proxy
+	host, _, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		host = req.Host
+	}
+	return "//" + net.JoinHostPort(host, g.FlagConfig.GobServerPort) + "/livereload.js"
+}
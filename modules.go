@@ -0,0 +1,146 @@
+package gob
+
+import (
+	"bufio"
+	"errors"
+	"go/build"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// findModule walks up from dir looking for the enclosing go.mod file.
+// It returns the directory containing go.mod and the module path declared in it
+func findModule(dir string) (root, modPath string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for {
+		modFile := filepath.Join(dir, "go.mod")
+		if info, err := os.Stat(modFile); err == nil && !info.IsDir() {
+			modPath, err = readModulePath(modFile)
+			return dir, modPath, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", errors.New("no go.mod found")
+		}
+		dir = parent
+	}
+}
+
+// readModulePath returns the path from the "module" directive of a go.mod file
+func readModulePath(modFile string) (string, error) {
+	f, err := os.Open(modFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "module") {
+			continue
+		}
+
+		modPath := strings.TrimSpace(strings.TrimPrefix(line, "module"))
+		if i := strings.Index(modPath, "//"); i >= 0 {
+			modPath = strings.TrimSpace(modPath[:i])
+		}
+		if unquoted, err := strconv.Unquote(modPath); err == nil {
+			modPath = unquoted
+		}
+		if modPath != "" {
+			return modPath, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", errors.New("no module directive in " + modFile)
+}
+
+// detectModule uses the module of the first of the given directories that is
+// inside one, so that import paths of that module resolve before any file or
+// directory of it has been seen. It does nothing once a module has been found
+func (g *Gob) detectModule(dirs ...string) {
+	if g.ModuleRoot != "" {
+		return
+	}
+
+	for _, dir := range dirs {
+		if root, modPath, err := findModule(dir); err == nil {
+			g.ModuleRoot = root
+			g.ModulePath = modPath
+			return
+		}
+	}
+}
+
+// checkIsModuleSource resolves a file or directory on disk (or an import path
+// inside the current module) to its import path using the enclosing go.mod
+func (g *Gob) checkIsModuleSource(buildDir, path string) ([]string, bool) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		// Allow import paths that live inside a module we've already found
+		if g.ModulePath == "" || !(path == g.ModulePath || strings.HasPrefix(path, g.ModulePath+"/")) {
+			return nil, false
+		}
+
+		absPath = filepath.Join(g.ModuleRoot, filepath.FromSlash(strings.TrimPrefix(path, g.ModulePath)))
+		if info, err = os.Stat(absPath); err != nil {
+			return nil, false
+		}
+	}
+
+	toReturn := make([]string, 4) // [0]=dir, [1]=filename, [2]=pkgPath [3]=binary
+	if info.IsDir() {
+		toReturn[0] = absPath
+	} else {
+		toReturn[0], toReturn[1] = filepath.Split(absPath)
+	}
+
+	root, modPath, err := findModule(toReturn[0])
+	if err != nil {
+		return nil, false
+	}
+
+	rel, err := filepath.Rel(root, toReturn[0])
+	if err != nil {
+		return nil, false
+	}
+
+	toReturn[2] = modPath
+	if rel != "." {
+		toReturn[2] = modPath + "/" + filepath.ToSlash(rel)
+	}
+	toReturn[3] = buildDir + "/" + filepath.Base(toReturn[2])
+
+	g.ModuleRoot = root
+	g.ModulePath = modPath
+
+	return toReturn, true
+}
+
+// pkgDir returns the directory on disk that holds the given import path.
+// In module mode it is resolved through the module graph (including the module cache)
+func (g *Gob) pkgDir(pkg string) string {
+	if g.ModuleRoot != "" {
+		if p, err := build.Import(pkg, g.ModuleRoot, build.FindOnly); err == nil && p.Dir != "" {
+			return p.Dir
+		}
+	}
+
+	return filepath.Join(g.Config.SrcDir, pkg)
+}
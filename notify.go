@@ -17,6 +17,9 @@ func initNotifications() {
 		}, gntp.Notification{
 			Event:   "failed",
 			Enabled: true,
+		}, gntp.Notification{
+			Event:   "crashed",
+			Enabled: true,
 		},
 	})
 }
@@ -44,3 +47,14 @@ func notifyFailed() {
 		inErrorState = true
 	}
 }
+
+func notifyCrashed(name, status string) {
+	growlClient.Notify(&gntp.Message{
+		Event: "crashed",
+		Title: "Application Crashed",
+		Text:  name + " exited (" + status + ")",
+	})
+
+	// The next successful start should let us know things are fixed
+	inErrorState = true
+}
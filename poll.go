@@ -0,0 +1,208 @@
+package gob
+
+import (
+	"crypto/sha1"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileState is what the pollWatcher remembers about a file between scans
+type fileState struct {
+	modTime time.Time
+	size    int64
+	isDir   bool
+	hash    []byte // only set when hashing is enabled
+}
+
+// pollWatcher is a fileWatcher for filesystems where fsnotify never delivers
+// any events (e.g. NFS, Vagrant shares and some container bind mounts).
+// It scans the watched directories every interval and compares the
+// modification time and size (and optionally the contents) of every file
+type pollWatcher struct {
+	interval time.Duration
+	hash     bool
+
+	events chan fileEvent
+	errors chan error
+	done   chan struct{}
+
+	mu   sync.Mutex
+	dirs map[string]map[string]fileState // watched directory -> file name -> state
+}
+
+func newPollWatcher(interval time.Duration, hash bool) *pollWatcher {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	w := &pollWatcher{
+		interval: interval,
+		hash:     hash,
+		events:   make(chan fileEvent),
+		errors:   make(chan error),
+		done:     make(chan struct{}),
+		dirs:     make(map[string]map[string]fileState),
+	}
+	go w.poll()
+
+	return w
+}
+
+// Watch starts polling dir. Only changes after this call are reported
+func (w *pollWatcher) Watch(dir string) error {
+	files, err := w.scan(dir)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.dirs[dir] = files
+	w.mu.Unlock()
+
+	return nil
+}
+
+// RemoveWatch stops polling dir
+func (w *pollWatcher) RemoveWatch(dir string) error {
+	w.mu.Lock()
+	delete(w.dirs, dir)
+	w.mu.Unlock()
+
+	return nil
+}
+
+func (w *pollWatcher) Events() <-chan fileEvent { return w.events }
+func (w *pollWatcher) Errors() <-chan error     { return w.errors }
+
+// Close stops polling
+func (w *pollWatcher) Close() error {
+	close(w.done)
+	return nil
+}
+
+func (w *pollWatcher) poll() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, ev := range w.changes() {
+				w.events <- ev
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// changes rescans every watched directory and returns what changed since the last scan
+func (w *pollWatcher) changes() []fileEvent {
+	w.mu.Lock()
+	dirs := make([]string, 0, len(w.dirs))
+	for dir := range w.dirs {
+		dirs = append(dirs, dir)
+	}
+	w.mu.Unlock()
+
+	var events []fileEvent
+	for _, dir := range dirs {
+		files, err := w.scan(dir)
+		if os.IsNotExist(err) {
+			// Report the directory itself as removed, like fsnotify does
+			w.RemoveWatch(dir)
+			events = append(events, fileEvent{Name: dir, Op: opDelete})
+			continue
+		}
+		if err != nil {
+			select {
+			case w.errors <- err:
+			case <-w.done:
+			}
+			continue
+		}
+
+		w.mu.Lock()
+		old, ok := w.dirs[dir]
+		if ok {
+			w.dirs[dir] = files
+		}
+		w.mu.Unlock()
+
+		// The directory stopped being watched while we were scanning it
+		if !ok {
+			continue
+		}
+
+		for name, state := range files {
+			prev, existed := old[name]
+			switch {
+			case !existed:
+				events = append(events, fileEvent{Name: filepath.Join(dir, name), Op: opCreate})
+			case state.changedFrom(prev):
+				events = append(events, fileEvent{Name: filepath.Join(dir, name), Op: opModify})
+			}
+		}
+		for name := range old {
+			if _, exists := files[name]; !exists {
+				events = append(events, fileEvent{Name: filepath.Join(dir, name), Op: opDelete})
+			}
+		}
+	}
+
+	return events
+}
+
+// changedFrom reports whether a file changed between two scans
+func (s fileState) changedFrom(prev fileState) bool {
+	if s.isDir {
+		// Changes inside sub directories are reported by their own watch
+		return false
+	}
+	if !s.modTime.Equal(prev.modTime) || s.size != prev.size {
+		return true
+	}
+	return s.hash != nil && prev.hash != nil && string(s.hash) != string(prev.hash)
+}
+
+// scan returns the state of every entry in dir
+func (w *pollWatcher) scan(dir string) (map[string]fileState, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]fileState, len(infos))
+	for _, info := range infos {
+		state := fileState{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+			isDir:   info.IsDir(),
+		}
+		if w.hash && !state.isDir {
+			state.hash = hashFile(filepath.Join(dir, info.Name()))
+		}
+		files[info.Name()] = state
+	}
+
+	return files, nil
+}
+
+// hashFile returns the SHA-1 of a file's contents (nil if it can't be read)
+func hashFile(path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil
+	}
+	return h.Sum(nil)
+}
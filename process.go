@@ -0,0 +1,318 @@
+package gob
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Process is a child process started by gob. Every process has a supervisor
+// goroutine that waits on it, reports how it exited and, when RestartOnCrash
+// is set, restarts it with an exponential backoff if it crashes
+type Process struct {
+	*exec.Cmd
+	Pkg string // the World package the process runs ("" for the application)
+
+	newCmd   func() *exec.Cmd // creates the command again when restarting
+	started  time.Time
+	restarts int             // how many times in a row it has been restarted after crashing
+	done     chan struct{}   // closed once the process has exited
+	stopping int32           // set (atomically) when gob stops the process on purpose
+	group    bool            // runs in its own process group, along with every process it starts
+	probe    *readinessProbe // checks whether the process has started up (nil when there is no check)
+}
+
+const (
+	stableUptime = 30 * time.Second // a process that ran this long resets its restart count
+	maxBackoff   = 30 * time.Second // the longest gob waits before restarting a crashed process
+)
+
+// startProcess starts the command returned by newCmd and supervises it
+func (g *Gob) startProcess(pkg string, newCmd func() *exec.Cmd, restarts int) (*Process, error) {
+	p := &Process{
+		Cmd:      newCmd(),
+		Pkg:      pkg,
+		newCmd:   newCmd,
+		restarts: restarts,
+		done:     make(chan struct{}),
+	}
+
+	if ready := g.readiness(pkg); ready != nil {
+		probe, err := newProbe(ready, p.Cmd)
+		if err != nil {
+			g.PrintErr(err)
+		}
+		p.probe = probe
+	}
+
+	// Give the process its own process group so that the processes it starts
+	// (e.g. workers or `sh -c` wrappers) are stopped along with it. A process
+	// reading gob's stdin has to stay in the terminal's foreground group
+	if p.Stdin == nil {
+		p.setGroup()
+	}
+
+	if err := p.Start(); err != nil {
+		return nil, err
+	}
+	p.started = time.Now()
+
+	go g.supervise(p)
+	return p, nil
+}
+
+// name describes the process in messages
+func (p *Process) name() string {
+	if p.Pkg == "" {
+		return "application"
+	}
+	return p.Pkg
+}
+
+// supervise waits for a process to exit and reports its exit status,
+// unless gob stopped it on purpose
+func (g *Gob) supervise(p *Process) {
+	err := p.Wait()
+	close(p.done)
+
+	if atomic.LoadInt32(&p.stopping) == 1 {
+		return
+	}
+
+	uptime := time.Since(p.started)
+	g.Print(fmt.Sprintf("%s exited after %v (%v)", p.name(), uptime.Round(time.Millisecond), p.ProcessState))
+
+	// Exiting cleanly isn't a crash
+	if err == nil {
+		return
+	}
+	notifyCrashed(p.name(), p.ProcessState.String())
+
+	if !g.FlagConfig.RestartOnCrash {
+		return
+	}
+
+	// The processes it started would otherwise hold on to its ports
+	if p.groupAlive() {
+		p.kill()
+	}
+
+	restarts := p.restarts
+	if uptime >= stableUptime {
+		restarts = 0
+	}
+	if g.FlagConfig.MaxRestarts > 0 && restarts >= g.FlagConfig.MaxRestarts {
+		g.Print(fmt.Sprintf("giving up on restarting %s after %d attempts", p.name(), restarts))
+		return
+	}
+
+	delay := restartDelay(time.Duration(g.FlagConfig.RestartBackoff)*time.Millisecond, restarts)
+	g.Print(fmt.Sprintf("restarting %s in %v...", p.name(), delay))
+	time.Sleep(delay)
+
+	g.procsMu.Lock()
+	defer g.procsMu.Unlock()
+
+	// Gob may have stopped or replaced the process in the meantime (e.g. after a rebuild)
+	if (p.Pkg == "" && g.Cmd != p) || (p.Pkg != "" && g.Procs[p.Pkg] != p) {
+		return
+	}
+
+	next, err := g.startProcess(p.Pkg, p.newCmd, restarts+1)
+	if err != nil {
+		g.PrintErr(err)
+		next = nil
+	} else if next.probe != nil {
+		go func() {
+			if g.waitReady(next) {
+				notifyFixed()
+			}
+		}()
+	}
+
+	if p.Pkg == "" {
+		g.Cmd = next
+	} else if next == nil {
+		delete(g.Procs, p.Pkg)
+	} else {
+		g.Procs[p.Pkg] = next
+	}
+}
+
+// restartDelay returns how long to wait before restarting a process that has
+// already been restarted the given number of times in a row: the backoff,
+// doubled for every restart, up to maxBackoff. A zero backoff never waits
+func restartDelay(backoff time.Duration, restarts int) time.Duration {
+	delay := backoff
+	for i := 0; i < restarts && delay > 0 && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+// setCmd records the running application process
+func (g *Gob) setCmd(p *Process) {
+	g.procsMu.Lock()
+	defer g.procsMu.Unlock()
+	g.Cmd = p
+}
+
+// setProc records the running process of a World package
+func (g *Gob) setProc(pkg string, p *Process) {
+	g.procsMu.Lock()
+	defer g.procsMu.Unlock()
+	if p == nil {
+		delete(g.Procs, pkg)
+		return
+	}
+	g.Procs[pkg] = p
+}
+
+// running reports whether the application or any World process is still running
+func (g *Gob) running() bool {
+	g.procsMu.Lock()
+	defer g.procsMu.Unlock()
+
+	procs := []*Process{g.Cmd}
+	for _, p := range g.Procs {
+		procs = append(procs, p)
+	}
+	for _, p := range procs {
+		if p == nil {
+			continue
+		}
+		select {
+		case <-p.done:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// signalApp sends a signal to the running application and every running World process
+func (g *Gob) signalApp(sig syscall.Signal) {
+	g.procsMu.Lock()
+	var procs []*Process
+	if g.Cmd != nil {
+		procs = append(procs, g.Cmd)
+	}
+	for _, p := range g.Procs {
+		procs = append(procs, p)
+	}
+	g.procsMu.Unlock()
+
+	for _, p := range procs {
+		if err := p.signal(sig); err != nil {
+			g.PrintErr(fmt.Errorf("could not forward %v to %s: %v", sig, p.name(), err))
+		}
+	}
+}
+
+// stopApp gracefully stops the running application, including every
+// running World process
+func (g *Gob) stopApp() {
+	g.procsMu.Lock()
+	var procs []*Process
+	if g.Cmd != nil {
+		procs = append(procs, g.Cmd)
+		g.Cmd = nil
+	}
+	for pkg, p := range g.Procs {
+		procs = append(procs, p)
+		delete(g.Procs, pkg)
+	}
+	g.procsMu.Unlock()
+
+	g.stopProcesses(procs)
+}
+
+// stopPkgs gracefully stops the running processes of the given World packages
+func (g *Gob) stopPkgs(pkgs []string) {
+	g.procsMu.Lock()
+	var procs []*Process
+	for _, pkg := range pkgs {
+		if p, ok := g.Procs[pkg]; ok {
+			procs = append(procs, p)
+			delete(g.Procs, pkg)
+		}
+	}
+	g.procsMu.Unlock()
+
+	g.stopProcesses(procs)
+}
+
+// stopProcesses stops all of the given processes concurrently
+func (g *Gob) stopProcesses(procs []*Process) {
+	var wg sync.WaitGroup
+	for _, p := range procs {
+		wg.Add(1)
+		go func(p *Process) {
+			defer wg.Done()
+			g.stopProcess(p)
+		}(p)
+	}
+	wg.Wait()
+}
+
+// exit stops everything gob started and exits with the given code
+func (g *Gob) exit(code int) {
+	g.stopApp()
+	os.Exit(code)
+}
+
+// stopProcess sends the configured stop signal to a process (and the processes
+// it started) and gives them StopTimeout milliseconds to exit on their own
+// before killing them
+func (g *Gob) stopProcess(p *Process) {
+	if p == nil || p.Process == nil {
+		return
+	}
+
+	// Let the supervisor know this isn't a crash
+	atomic.StoreInt32(&p.stopping, 1)
+
+	sig, err := parseSignal(g.FlagConfig.StopSignal)
+	if err != nil {
+		g.PrintErr(err)
+		sig = syscall.SIGTERM
+	}
+
+	if err := p.signal(sig); err != nil {
+		// The process has most likely exited already
+		p.kill()
+	}
+
+	timeout := time.NewTimer(time.Duration(g.FlagConfig.StopTimeout) * time.Millisecond)
+	defer timeout.Stop()
+
+	select {
+	case <-p.done:
+	case <-timeout.C:
+		g.Print(p.name() + " did not shut down in time, killing it...")
+		p.kill()
+		<-p.done
+		return
+	}
+
+	// The processes it started get the rest of the timeout to exit too
+	for p.groupAlive() {
+		select {
+		case <-timeout.C:
+			g.Print("processes started by " + p.name() + " did not shut down in time, killing them...")
+			p.kill()
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package gob
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartDelay(t *testing.T) {
+	tests := []struct {
+		backoff  time.Duration
+		restarts int
+		want     time.Duration
+	}{
+		{500 * time.Millisecond, 0, 500 * time.Millisecond},
+		{500 * time.Millisecond, 3, 4 * time.Second},
+		{500 * time.Millisecond, 6, maxBackoff},
+		{500 * time.Millisecond, 100, maxBackoff},
+		{0, 0, 0},
+		{0, 100, 0},
+	}
+
+	for _, test := range tests {
+		if got := restartDelay(test.backoff, test.restarts); got != test.want {
+			t.Errorf("restartDelay(%v, %d) = %v, want %v", test.backoff, test.restarts, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package gob
+
+import "syscall"
+
+// setGroup starts the process in a process group of its own
+func (p *Process) setGroup() {
+	p.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	p.group = true
+}
+
+// signal sends a signal to the process, including
+// every process it started when it has its own process group
+func (p *Process) signal(sig syscall.Signal) error {
+	if p.group {
+		return syscall.Kill(-p.Process.Pid, sig)
+	}
+	return p.Process.Signal(sig)
+}
+
+// kill kills the process and everything left in its process group
+func (p *Process) kill() {
+	p.signal(syscall.SIGKILL)
+}
+
+// groupAlive reports whether any process is left in the process group
+func (p *Process) groupAlive() bool {
+	return p.group && syscall.Kill(-p.Process.Pid, 0) == nil
+}
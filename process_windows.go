@@ -0,0 +1,23 @@
+package gob
+
+import "syscall"
+
+// setGroup does nothing on Windows, which has no process groups
+// that can be signalled. Only the process itself is stopped
+func (p *Process) setGroup() {}
+
+// signal sends a signal to the process. Windows can only kill
+// processes, so any other signal fails and the caller falls back to kill
+func (p *Process) signal(sig syscall.Signal) error {
+	return p.Process.Signal(sig)
+}
+
+// kill kills the process
+func (p *Process) kill() {
+	p.Process.Kill()
+}
+
+// groupAlive is always false since processes never get a group of their own
+func (p *Process) groupAlive() bool {
+	return false
+}
@@ -0,0 +1,248 @@
+package gob
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyDialTimeout is how long the proxy keeps trying to reach an
+// application that isn't accepting connections (yet)
+const proxyDialTimeout = 10 * time.Second
+
+// restartProxy is a reverse proxy in front of the application. It holds on to
+// incoming requests while the application restarts, and shows the output of
+// the build when it fails
+type restartProxy struct {
+	proxy  *httputil.ReverseProxy
+	script func(req *http.Request) string // the URL of the live reload script for a page ("" when off)
+
+	mu       sync.Mutex
+	ready    chan struct{} // closed while requests can be passed on to the application
+	held     bool          // whether requests are being held
+	buildErr string        // the output of the last failed build ("" when it succeeded)
+}
+
+// startProxy starts the proxy when GobFlags.Proxy is set
+func (g *Gob) startProxy() {
+	if g.FlagConfig.Proxy == "" {
+		return
+	}
+	if g.FlagConfig.NoRunMode {
+		g.Print("the proxy isn't started since the application isn't run")
+		return
+	}
+
+	target := g.FlagConfig.ProxyTarget
+	if target == "" && g.FlagConfig.Ready != nil {
+		target = g.FlagConfig.Ready.TCP
+	}
+	if target == "" {
+		g.Print("the proxy needs the address of the application (see -proxyTarget)")
+		return
+	}
+
+	listener, err := net.Listen("tcp", g.FlagConfig.Proxy)
+	if err != nil {
+		g.PrintErr(err)
+		return
+	}
+
+	g.proxy = newRestartProxy(target, g.liveReloadScript)
+	g.Print("proxying " + g.FlagConfig.Proxy + " to " + target + "...")
+	go func() {
+		if err := http.Serve(listener, g.proxy); err != nil {
+			g.PrintErr(err)
+		}
+	}()
+}
+
+// newRestartProxy returns a proxy to the given address. Requests are
+// held until the application has been started for the first time
+func newRestartProxy(target string, script func(req *http.Request) string) *restartProxy {
+	p := &restartProxy{
+		proxy:  httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: target}),
+		script: script,
+		ready:  make(chan struct{}),
+		held:   true,
+	}
+
+	// Keep trying while the application is starting up,
+	// instead of failing on the first refused connection
+	dialer := &net.Dialer{Timeout: time.Second}
+	p.proxy.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			deadline := time.Now().Add(proxyDialTimeout)
+			for {
+				conn, err := dialer.DialContext(ctx, network, addr)
+				if err == nil || time.Now().After(deadline) {
+					return conn, err
+				}
+
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+		},
+	}
+	p.proxy.FlushInterval = 100 * time.Millisecond
+	p.proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		renderErrorPage(w, http.StatusBadGateway, "Application Unavailable", err.Error(), p.script(r))
+	}
+	p.proxy.ModifyResponse = p.injectScript
+
+	return p
+}
+
+func (p *restartProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	ready := p.ready
+	p.mu.Unlock()
+
+	// Wait for the application to be (re)started
+	select {
+	case <-ready:
+	case <-r.Context().Done():
+		return
+	}
+
+	p.mu.Lock()
+	buildErr := p.buildErr
+	p.mu.Unlock()
+
+	if buildErr != "" {
+		renderErrorPage(w, http.StatusInternalServerError, "Build Failed", buildErr, p.script(r))
+		return
+	}
+	p.proxy.ServeHTTP(w, r)
+}
+
+// hold makes incoming requests wait until release is called.
+// All of the methods do nothing when the proxy isn't running
+func (p *restartProxy) hold() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.held {
+		p.ready = make(chan struct{})
+		p.held = true
+	}
+}
+
+// release passes the held requests and every new one on to the application
+func (p *restartProxy) release() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.held {
+		close(p.ready)
+		p.held = false
+	}
+}
+
+// buildFailed serves the output of the failed build to every request
+// until the next build succeeds
+func (p *restartProxy) buildFailed(output string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	p.buildErr = output
+	p.mu.Unlock()
+
+	// The held requests get to see the error too
+	p.release()
+}
+
+// buildFixed stops serving the output of the last failed build
+func (p *restartProxy) buildFixed() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	p.buildErr = ""
+	p.mu.Unlock()
+}
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>gob: {{.Title}}</title>
+<style>
+  body { margin: 0; background: #1e1e1e; color: #ddd; font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; }
+  header { background: #c0392b; color: #fff; padding: 16px 24px; }
+  header h1 { margin: 0; font-size: 20px; }
+  header p { margin: 4px 0 0; opacity: .8; font-size: 13px; }
+  pre { margin: 0; padding: 24px; font: 13px/1.5 Menlo, Consolas, monospace; white-space: pre-wrap; word-wrap: break-word; }
+</style>
+</head>
+<body>
+<header>
+  <h1>{{.Title}}</h1>
+  <p>gob is waiting for changes{{if not .Script}}, refresh this page once they're in{{end}}</p>
+</header>
+<pre>{{.Output}}</pre>
+{{if .Script}}<script src="{{.Script}}"></script>{{end}}
+</body>
+</html>
+`))
+
+// renderErrorPage shows what went wrong in place of the application
+func renderErrorPage(w http.ResponseWriter, status int, title, output, script string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	errorPage.Execute(w, struct {
+		Title  string
+		Output string
+		Script string
+	}{title, output, script})
+}
+
+// injectScript adds the live reload script to the HTML pages of the application
+func (p *restartProxy) injectScript(resp *http.Response) error {
+	script := p.script(resp.Request)
+	if script == "" || resp.Header.Get("Content-Encoding") != "" ||
+		!strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	// Add the script at the end of the body, or of the page when it has none
+	tag := []byte(`<script src="` + template.HTMLEscapeString(script) + `"></script>`)
+	if i := bytes.LastIndex(body, []byte("</body>")); i >= 0 {
+		body = append(body[:i], append(tag, body[i:]...)...)
+	} else {
+		body = append(body, tag...)
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
@@ -0,0 +1,216 @@
+package gob
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReadyTimeout  = 30 * time.Second
+	defaultReadyInterval = 100 * time.Millisecond
+)
+
+// Readiness describes how gob tells that a process has finished starting up.
+// Every check that is set has to pass:
+//
+//	{"tcp": "localhost:8080", "http": "/healthz", "log": "listening on", "timeout": 10000}
+type Readiness struct {
+	TCP      string `json:"tcp"`      // an address that has to accept connections (e.g. "localhost:8080")
+	HTTP     string `json:"http"`     // a URL, or a path on the tcp address, that has to return a 2xx status
+	Log      string `json:"log"`      // a regular expression a line of output has to match
+	Timeout  int    `json:"timeout"`  // milliseconds to wait for the process to become ready (default 30000)
+	Interval int    `json:"interval"` // milliseconds between the tcp and http checks (default 100)
+}
+
+// readiness returns the readiness check of the given package
+// ("" for the application), or nil when there is none
+func (g *Gob) readiness(pkg string) *Readiness {
+	if pkg == "" {
+		return g.FlagConfig.Ready
+	}
+	if w, ok := g.WorldPkgs[pkg]; ok {
+		return w.Ready
+	}
+	return nil
+}
+
+// readinessProbe checks whether a single process has become ready
+type readinessProbe struct {
+	*Readiness
+	url     string
+	pattern *regexp.Regexp
+	matched chan struct{} // closed once a line of output matched the pattern
+	once    sync.Once
+}
+
+// newProbe prepares the checks of r for the given command,
+// which has to happen before the command is started
+func newProbe(r *Readiness, cmd *exec.Cmd) (*readinessProbe, error) {
+	probe := &readinessProbe{
+		Readiness: r,
+		url:       r.HTTP,
+		matched:   make(chan struct{}),
+	}
+
+	if strings.HasPrefix(r.HTTP, "/") {
+		if r.TCP == "" {
+			return nil, errors.New("readiness check: the http path " + r.HTTP + " needs a tcp address")
+		}
+		probe.url = "http://" + r.TCP + r.HTTP
+	}
+
+	if r.Log != "" {
+		pattern, err := regexp.Compile(r.Log)
+		if err != nil {
+			return nil, fmt.Errorf("readiness check: %v", err)
+		}
+		probe.pattern = pattern
+
+		// Look at the output of the process on its way through
+		cmd.Stdout = &lineMatcher{w: cmd.Stdout, probe: probe}
+		cmd.Stderr = &lineMatcher{w: cmd.Stderr, probe: probe}
+	}
+
+	return probe, nil
+}
+
+func (r *readinessProbe) timeout() time.Duration {
+	if r.Timeout <= 0 {
+		return defaultReadyTimeout
+	}
+	return time.Duration(r.Timeout) * time.Millisecond
+}
+
+func (r *readinessProbe) interval() time.Duration {
+	if r.Interval <= 0 {
+		return defaultReadyInterval
+	}
+	return time.Duration(r.Interval) * time.Millisecond
+}
+
+// wait waits until every check has passed. It gives up when the
+// process exits or the timeout expires
+func (r *readinessProbe) wait(p *Process) error {
+	timeout := time.NewTimer(r.timeout())
+	defer timeout.Stop()
+	ticker := time.NewTicker(r.interval())
+	defer ticker.Stop()
+
+	client := &http.Client{Timeout: time.Second}
+	tcpReady := r.TCP == ""
+	httpReady := r.url == ""
+	logReady := r.pattern == nil
+
+	for {
+		if !tcpReady {
+			if conn, err := net.DialTimeout("tcp", r.TCP, time.Second); err == nil {
+				conn.Close()
+				tcpReady = true
+			}
+		}
+		if tcpReady && !httpReady {
+			if resp, err := client.Get(r.url); err == nil {
+				resp.Body.Close()
+				httpReady = resp.StatusCode >= 200 && resp.StatusCode < 300
+			}
+		}
+		if tcpReady && httpReady && logReady {
+			return nil
+		}
+
+		select {
+		case <-r.matched:
+			logReady = true
+		case <-ticker.C:
+		case <-p.done:
+			return errExitedEarly
+		case <-timeout.C:
+			return fmt.Errorf("%s is not ready after %v", p.name(), r.timeout())
+		}
+	}
+}
+
+var errExitedEarly = errors.New("exited before it was ready")
+
+// lineMatcher passes output through while matching each
+// line against the log pattern of a readiness probe
+type lineMatcher struct {
+	w     io.Writer
+	probe *readinessProbe
+
+	mu   sync.Mutex
+	line []byte // the part of the current line written so far
+}
+
+func (m *lineMatcher) Write(b []byte) (int, error) {
+	m.mu.Lock()
+	m.line = append(m.line, b...)
+	for {
+		i := bytes.IndexByte(m.line, '\n')
+		if i < 0 {
+			break
+		}
+		if m.probe.pattern.Match(m.line[:i]) {
+			m.probe.once.Do(func() { close(m.probe.matched) })
+		}
+		m.line = m.line[i+1:]
+	}
+	m.mu.Unlock()
+
+	if m.w == nil {
+		return ioutil.Discard.Write(b)
+	}
+	return m.w.Write(b)
+}
+
+// waitReady waits for the given processes to pass their readiness checks
+// and reports how long it took. Only a check that times out counts as a
+// failure; a process that exits is reported by its supervisor
+func (g *Gob) waitReady(procs ...*Process) bool {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		ready = true
+	)
+
+	for _, p := range procs {
+		if p == nil || p.probe == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(p *Process) {
+			defer wg.Done()
+
+			err := p.probe.wait(p)
+			if err == nil {
+				g.Print(fmt.Sprintf("%s is ready after %v", p.name(), time.Since(p.started).Round(time.Millisecond)))
+				return
+			}
+
+			mu.Lock()
+			ready = false
+			mu.Unlock()
+
+			if err == errExitedEarly {
+				g.Print(p.name() + " " + err.Error())
+				return
+			}
+			notifyFailed()
+			g.PrintErr(err)
+		}(p)
+	}
+	wg.Wait()
+
+	return ready
+}
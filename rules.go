@@ -0,0 +1,190 @@
+package gob
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// The actions a Rule can map changed files to
+const (
+	ActionRebuild = "rebuild" // rebuild and restart the application
+	ActionRestart = "restart" // restart the application without rebuilding it
+	ActionNotify  = "notify"  // notify the subscribed Gob Agents
+	ActionCommand = "command" // run a shell command
+	ActionIgnore  = "ignore"  // do nothing
+
+	ActionReload     = "reload"      // reload the browsers connected for live reload
+	ActionRefreshCSS = "css-refresh" // reload the stylesheets of the browsers connected for live reload
+)
+
+// Rule maps the files matching a gitignore-style pattern (relative to the
+// package directory, or to a watched dependency) to what gob should do when
+// they change:
+//
+//	"rules": [
+//	  {"pattern": "*.tmpl", "action": "notify"},
+//	  {"pattern": "migrations/*.sql", "action": "command", "command": "make migrate"},
+//	  {"pattern": "*_gen.go", "action": "ignore"}
+//	]
+type Rule struct {
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`  // one of the Action constants
+	Command string `json:"command"` // the shell command of the "command" action
+}
+
+// compiledRule is a Rule with its pattern parsed for each watched package and dependency
+type compiledRule struct {
+	Rule
+	matchers []*ignoreRule
+}
+
+// changeSet is a batch of changed files grouped by the action they trigger
+type changeSet struct {
+	rebuild  []string
+	restart  []string
+	notify   []string
+	reload   []string
+	css      []string
+	commands map[string][]string // command -> the files that triggered it
+}
+
+// compileRules builds the rule table from the rules in GobFlags, followed by
+// the stylesheets (when live reloading), the restart files, the extensions
+// the hooks are interested in and the restart files of the World packages.
+// The defaults for Config.IgnoreTypes, Config.RestartTypes, Config.BuildTypes
+// and Config.TemplateTypes come last, so that everything configured
+// explicitly takes precedence over them. The first matching rule wins
+func (g *Gob) compileRules(roots []string) ([]*compiledRule, error) {
+	rules := append([]Rule(nil), g.FlagConfig.Rules...)
+	if g.FlagConfig.LiveReload {
+		rules = append(rules, Rule{Pattern: "*.css", Action: ActionRefreshCSS})
+	}
+	for _, pattern := range g.FlagConfig.RestartFiles {
+		rules = append(rules, Rule{Pattern: pattern, Action: ActionRestart})
+	}
+	for _, ext := range g.FlagConfig.Hooks.extensions() {
+		rules = append(rules, Rule{Pattern: "*" + ext, Action: ActionRebuild})
+	}
+
+	compiled, err := compileRuleList(rules, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	// The restart files of each World package are relative to that package
+	for _, pkg := range g.World {
+		if w, ok := g.WorldPkgs[pkg]; ok && len(w.RestartFiles) > 0 {
+			compiled = append(compiled, &compiledRule{
+				Rule:     Rule{Action: ActionRestart},
+				matchers: parseIgnoreRules(g.pkgDir(pkg), w.RestartFiles),
+			})
+		}
+	}
+
+	var defaults []Rule
+	for _, ext := range g.Config.IgnoreTypes {
+		defaults = append(defaults, Rule{Pattern: "*" + ext, Action: ActionIgnore})
+	}
+	for _, ext := range g.Config.RestartTypes {
+		defaults = append(defaults, Rule{Pattern: "*" + ext, Action: ActionRestart})
+	}
+	for _, ext := range g.Config.BuildTypes {
+		defaults = append(defaults, Rule{Pattern: "*" + ext, Action: ActionRebuild})
+	}
+	for _, ext := range g.Config.TemplateTypes {
+		defaults = append(defaults, Rule{Pattern: "*" + ext, Action: ActionNotify})
+	}
+
+	compiledDefaults, err := compileRuleList(defaults, roots)
+	if err != nil {
+		return nil, err
+	}
+	return append(compiled, compiledDefaults...), nil
+}
+
+// compileRuleList checks the actions of the rules and parses
+// their patterns relative to each of the roots
+func compileRuleList(rules []Rule, roots []string) ([]*compiledRule, error) {
+	var compiled []*compiledRule
+	for _, rule := range rules {
+		switch rule.Action {
+		case ActionRebuild, ActionRestart, ActionNotify, ActionIgnore, ActionReload, ActionRefreshCSS:
+		case ActionCommand:
+			if rule.Command == "" {
+				return nil, fmt.Errorf("rule %q is missing a command", rule.Pattern)
+			}
+		default:
+			return nil, fmt.Errorf("rule %q has an unknown action %q", rule.Pattern, rule.Action)
+		}
+
+		c := &compiledRule{Rule: rule}
+		for _, root := range roots {
+			if matcher, ok := newIgnoreRule(root, rule.Pattern); ok {
+				c.matchers = append(c.matchers, matcher)
+			}
+		}
+		compiled = append(compiled, c)
+	}
+
+	return compiled, nil
+}
+
+// match reports whether the rule applies to the given file
+func (r *compiledRule) match(file string) bool {
+	for _, matcher := range r.matchers {
+		if matcher.match(file, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyChanges groups the changed files by the action of the first rule
+// that matches them. Files that no rule matches are ignored
+func (g *Gob) classifyChanges(files []string) *changeSet {
+	changes := &changeSet{
+		commands: make(map[string][]string),
+	}
+
+	for _, file := range files {
+		for _, rule := range g.rules {
+			if !rule.match(file) {
+				continue
+			}
+
+			switch rule.Action {
+			case ActionRebuild:
+				changes.rebuild = append(changes.rebuild, file)
+			case ActionRestart:
+				changes.restart = append(changes.restart, file)
+			case ActionNotify:
+				changes.notify = append(changes.notify, file)
+			case ActionReload:
+				changes.reload = append(changes.reload, file)
+			case ActionRefreshCSS:
+				changes.css = append(changes.css, file)
+			case ActionCommand:
+				changes.commands[rule.Command] = append(changes.commands[rule.Command], file)
+			}
+			break
+		}
+	}
+
+	return changes
+}
+
+// runCommand runs the shell command of a "command" rule
+func (g *Gob) runCommand(command string, files []string) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = g.hookDir()
+	cmd.Env = append(os.Environ(), "GOB_CHANGED_FILES="+strings.Join(files, " "))
+	cmd.Stdout = g.Config.Stdout
+	cmd.Stderr = g.Config.Stderr
+
+	g.Print("running command... " + command)
+	if err := cmd.Run(); err != nil {
+		g.Print("command failed: " + err.Error())
+	}
+}
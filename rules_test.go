@@ -0,0 +1,26 @@
+package gob
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfiguredPatternsBeforeIgnoreTypes(t *testing.T) {
+	g := &Gob{Config: DefaultConfig(), FlagConfig: &GobFlags{
+		Hooks:        Hooks{PreBuild: []Hook{{Command: "sass style.scss style.css", Extensions: []string{".scss"}}}},
+		RestartFiles: []string{"*.png"},
+	}}
+	rules, err := g.compileRules([]string{"/r"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.rules = rules
+
+	changes := g.classifyChanges([]string{"/r/style.scss", "/r/logo.png", "/r/app.js"})
+	if want := []string{"/r/style.scss"}; !reflect.DeepEqual(changes.rebuild, want) {
+		t.Errorf("rebuild = %v, want %v", changes.rebuild, want)
+	}
+	if want := []string{"/r/logo.png"}; !reflect.DeepEqual(changes.restart, want) {
+		t.Errorf("restart = %v, want %v", changes.restart, want)
+	}
+}
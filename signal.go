@@ -1,12 +1,38 @@
 package gob
 
 import (
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
 
+// signals maps the names accepted in GobFlags to their signal.
+// The signals that only exist on Unix are added in "signal_unix.go"
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+// parseSignal looks up a signal by name (e.g. "SIGTERM", "term" or "TERM")
+func parseSignal(name string) (syscall.Signal, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	sig, ok := signals[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown signal %q", name)
+	}
+	return sig, nil
+}
+
 // this function allows us to tell gob to restart the process it is running
 func registerSignalHandlers(g *Gob) {
 	c := make(chan os.Signal, 1)
@@ -16,19 +42,87 @@ func registerSignalHandlers(g *Gob) {
 			// waiting for CTRL-C
 			select {
 			case <-time.After(time.Second):
-				// TODO(ttacon): ensure we kill child process
 				g.Print("\r[gob] exiting...")
-				os.Exit(0)
+				g.exit(0)
 			case <-c:
 				select {
 				case <-time.After(time.Millisecond * 300):
-					g.restartApp()
+					g.requestRestart()
 				case <-c:
-					// TODO(ttacon): ensure we kill child process
 					g.Print("\r[gob] exiting...")
-					os.Exit(0)
+					g.exit(0)
 				}
 			}
 		}
 	}()
 }
+
+// requestRestart has the Watch loop restart the application, so that the
+// restart doesn't run at the same time as the handling of a change
+func (g *Gob) requestRestart() {
+	select {
+	case g.commands <- "restart":
+	default:
+		g.Print("a restart is already pending")
+	}
+}
+
+// registerForwardedSignals passes the signals listed in GobFlags.ForwardSignals
+// on to the running application (or every running World process). It returns
+// the signals that are forwarded
+func registerForwardedSignals(g *Gob) []os.Signal {
+	var sigs []os.Signal
+	for _, name := range g.FlagConfig.ForwardSignals {
+		sig, err := parseSignal(name)
+		if err != nil {
+			g.PrintErr(err)
+			continue
+		}
+
+		// gob needs these for itself
+		if sig == syscall.SIGINT || sig == syscall.SIGKILL {
+			g.Print("cannot forward " + name + ", ignoring it")
+			continue
+		}
+		sigs = append(sigs, sig)
+	}
+
+	if len(sigs) == 0 {
+		return nil
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	go func() {
+		for sig := range c {
+			g.signalApp(sig.(syscall.Signal))
+		}
+	}()
+	return sigs
+}
+
+// registerExitSignals makes gob stop everything it started before exiting
+// when it's terminated, e.g. by `kill` or when its terminal is closed.
+// Signals that are forwarded to the application are left alone
+func registerExitSignals(g *Gob, forwarded []os.Signal) {
+	var sigs []os.Signal
+	for _, sig := range []os.Signal{syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT} {
+		isForwarded := false
+		for _, f := range forwarded {
+			if f == sig {
+				isForwarded = true
+			}
+		}
+		if !isForwarded {
+			sigs = append(sigs, sig)
+		}
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	go func() {
+		sig := <-c
+		g.Print(fmt.Sprintf("received %v, exiting...", sig))
+		g.exit(0)
+	}()
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package gob
+
+import "syscall"
+
+func init() {
+	signals["SIGUSR1"] = syscall.SIGUSR1
+	signals["SIGUSR2"] = syscall.SIGUSR2
+	signals["SIGCONT"] = syscall.SIGCONT
+	signals["SIGWINCH"] = syscall.SIGWINCH
+}
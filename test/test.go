@@ -14,7 +14,7 @@ func main() {
 	// Start up a GobAgent and register a handler.
 	// GobAgent provides a way for our applications to talk
 	// to the GobServer and listen for notifications.
-	go agent.StartGobAgentWithFunc("9035", "9034", handleFunc)
+	go agent.StartGobAgent("localhost:9034", handleFunc)
 
 	// Imitate server
 	for {
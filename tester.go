@@ -0,0 +1,224 @@
+package gob
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/b1lly/gob/dependencies"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// testEvent is a single line of `go test -json` output
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// testResult is the outcome of the tests of a single package
+type testResult struct {
+	pkg     string
+	passed  bool
+	skipped bool // e.g. the package has no test files
+	elapsed time.Duration
+}
+
+// Test runs `go test` for the given packages (limited to the TestRun pattern),
+// prints a summary per package and notifies whether the tests passed
+func (g *Gob) Test(pkgs []string) bool {
+	if len(pkgs) == 0 {
+		return true
+	}
+
+	options := g.FlagConfig.BuildOptions
+	args := []string{"test", "-json"}
+	if g.FlagConfig.TestRun != "" {
+		args = append(args, "-run", g.FlagConfig.TestRun)
+	}
+	if len(options.BuildTags) > 0 {
+		args = append(args, "-tags", strings.Join(options.BuildTags, ","))
+	}
+	if options.LDFlags != "" {
+		args = append(args, "-ldflags", options.LDFlags)
+	}
+	args = append(args, options.BuildArgs...)
+	args = append(args, pkgs...)
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = g.ModuleRoot
+	cmd.Stderr = g.Config.Stderr
+	if len(options.BuildEnv) > 0 {
+		cmd.Env = append(os.Environ(), options.BuildEnv...)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		g.PrintErr(err)
+		return false
+	}
+
+	g.Print("testing " + strings.Join(pkgs, ", ") + "...")
+	if err := cmd.Start(); err != nil {
+		notifyFailed()
+		g.PrintErr(err)
+		return false
+	}
+
+	// Pass the test output through as it comes in
+	// and keep track of the result of each package
+	results := make(map[string]*testResult)
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		var ev testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			fmt.Fprintln(g.Config.Stdout, scanner.Text())
+			continue
+		}
+
+		if ev.Output != "" {
+			fmt.Fprint(g.Config.Stdout, ev.Output)
+		}
+
+		if ev.Test == "" && ev.Package != "" && (ev.Action == "pass" || ev.Action == "fail" || ev.Action == "skip") {
+			results[ev.Package] = &testResult{
+				pkg:     ev.Package,
+				passed:  ev.Action != "fail",
+				skipped: ev.Action == "skip",
+				elapsed: time.Duration(ev.Elapsed * float64(time.Second)),
+			}
+		}
+	}
+
+	passed := cmd.Wait() == nil
+	g.printTestSummary(pkgs, results)
+
+	if passed {
+		notifyFixed()
+	} else {
+		notifyFailed()
+	}
+	return passed
+}
+
+func (g *Gob) printTestSummary(pkgs []string, results map[string]*testResult) {
+	g.Print("test summary:")
+	for _, pkg := range pkgs {
+		result, ok := results[pkg]
+		switch {
+		case !ok:
+			fmt.Printf("[gob]   ?     %s (no result)\n", pkg)
+		case result.skipped:
+			fmt.Printf("[gob]   ?     %s (no tests)\n", pkg)
+		case result.passed:
+			fmt.Printf("[gob]   ok    %s (%v)\n", pkg, result.elapsed)
+		default:
+			fmt.Printf("[gob]   FAIL  %s (%v)\n", pkg, result.elapsed)
+		}
+	}
+}
+
+// testChanged runs the tests of every watched package
+// that is affected by the changed files
+func (g *Gob) testChanged(files []string) {
+	var dirs []string
+	for _, file := range files {
+		dirs = append(dirs, filepath.Dir(file))
+	}
+
+	// The graph is only built again when packages were added or removed,
+	// and only resolves its imports again when the change touched them
+	pkgs := g.testPkgs()
+	if g.testGraph == nil || !equalStrings(g.testGraph.Pkgs, pkgs) {
+		g.testGraph = dependencies.NewGraph(&dependencies.Graph{
+			StdLib: false,
+			SrcDir: g.Config.SrcDir,
+			Dir:    g.ModuleRoot,
+			Pkgs:   pkgs,
+			Tests:  true,
+		})
+	} else if g.testGraph.ImportsChanged(dirs) {
+		g.testGraph.Refresh()
+	}
+
+	g.Test(g.testGraph.Affected(dirs))
+}
+
+// testPkgs returns the import paths of all the packages
+// in the directories that are being watched
+func (g *Gob) testPkgs() []string {
+	g.watchedMu.Lock()
+	dirs := make([]string, 0, len(g.watched))
+	for dir := range g.watched {
+		dirs = append(dirs, dir)
+	}
+	g.watchedMu.Unlock()
+	sort.Strings(dirs)
+
+	var pkgs []string
+	for _, dir := range dirs {
+		if !hasGoFiles(dir) {
+			continue
+		}
+		if pkg, ok := g.importPath(dir); ok {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+
+	return pkgs
+}
+
+// importPath returns the import path of the package in dir
+func (g *Gob) importPath(dir string) (string, bool) {
+	if g.ModuleRoot != "" {
+		rel, err := filepath.Rel(g.ModuleRoot, dir)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", false
+		}
+		if rel == "." {
+			return g.ModulePath, true
+		}
+		return g.ModulePath + "/" + filepath.ToSlash(rel), true
+	}
+
+	rel, err := filepath.Rel(g.Config.SrcDir, dir)
+	if err != nil || strings.HasPrefix(rel, "..") || rel == "." {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// hasGoFiles reports whether dir contains any Go source files
+func hasGoFiles(dir string) bool {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+
+	for _, info := range infos {
+		if !info.IsDir() && filepath.Ext(info.Name()) == ".go" {
+			return true
+		}
+	}
+	return false
+}
+
+// equalStrings reports whether a and b hold the same strings in the same order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package gob
+
+import (
+	"github.com/howeyc/fsnotify"
+	"time"
+)
+
+// fileOp describes what happened to a file
+type fileOp int
+
+const (
+	opCreate fileOp = iota
+	opModify
+	opDelete
+	opRename
+)
+
+// fileEvent is a single change reported by a fileWatcher
+type fileEvent struct {
+	Name string // the absolute path of the file or directory that changed
+	Op   fileOp
+}
+
+func (ev fileEvent) IsCreate() bool { return ev.Op == opCreate }
+func (ev fileEvent) IsModify() bool { return ev.Op == opModify }
+func (ev fileEvent) IsDelete() bool { return ev.Op == opDelete }
+func (ev fileEvent) IsRename() bool { return ev.Op == opRename }
+
+// fileWatcher reports changes to the files inside the directories it watches
+// (non-recursively). Watch uses fsnotify by default and falls back
+// to polling the filesystem when requested (see "poll.go")
+type fileWatcher interface {
+	Watch(dir string) error
+	RemoveWatch(dir string) error
+	Events() <-chan fileEvent
+	Errors() <-chan error
+	Close() error
+}
+
+// newFileWatcher returns the fileWatcher configured in GobFlags
+func (g *Gob) newFileWatcher() (fileWatcher, error) {
+	if g.FlagConfig.Poll {
+		interval := time.Duration(g.FlagConfig.PollInterval) * time.Millisecond
+		return newPollWatcher(interval, g.FlagConfig.PollHash), nil
+	}
+	return newNotifyWatcher()
+}
+
+// notifyWatcher is a fileWatcher backed by fsnotify
+type notifyWatcher struct {
+	*fsnotify.Watcher
+	events chan fileEvent
+}
+
+func newNotifyWatcher() (*notifyWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &notifyWatcher{
+		Watcher: watcher,
+		events:  make(chan fileEvent),
+	}
+	go w.translate()
+
+	return w, nil
+}
+
+// translate converts the fsnotify events into fileEvents
+func (w *notifyWatcher) translate() {
+	for ev := range w.Watcher.Event {
+		op := opModify
+		switch {
+		case ev.IsCreate():
+			op = opCreate
+		case ev.IsDelete():
+			op = opDelete
+		case ev.IsRename():
+			op = opRename
+		}
+		w.events <- fileEvent{Name: ev.Name, Op: op}
+	}
+	close(w.events)
+}
+
+func (w *notifyWatcher) Events() <-chan fileEvent { return w.events }
+func (w *notifyWatcher) Errors() <-chan error     { return w.Watcher.Error }
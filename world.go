@@ -0,0 +1,148 @@
+package gob
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// BuildOptions are the extra settings gob passes to `go build`
+type BuildOptions struct {
+	BuildArgs []string `json:"buildArgs"` // extra arguments for go build (e.g. "-race")
+	BuildTags []string `json:"buildTags"` // build tags passed with -tags
+	LDFlags   string   `json:"ldflags"`   // flags passed with -ldflags (e.g. "-X main.version=dev")
+	BuildEnv  []string `json:"buildEnv"`  // KEY=VALUE pairs added to the environment of go build
+}
+
+// merge returns a copy of the options where every setting
+// that is set in overrides replaces the one in b
+func (b BuildOptions) merge(overrides BuildOptions) BuildOptions {
+	if overrides.BuildArgs != nil {
+		b.BuildArgs = overrides.BuildArgs
+	}
+	if overrides.BuildTags != nil {
+		b.BuildTags = overrides.BuildTags
+	}
+	if overrides.LDFlags != "" {
+		b.LDFlags = overrides.LDFlags
+	}
+	if overrides.BuildEnv != nil {
+		b.BuildEnv = overrides.BuildEnv
+	}
+	return b
+}
+
+// WorldPackage is a single entry of a World build file. An entry is either
+// the name of the package or an object that also holds per-package settings:
+//
+//	["github.com/you/app/api", {"package": "github.com/you/app/worker", "args": ["-queue=dev"]}]
+type WorldPackage struct {
+	Package string   `json:"package"`
+	Name    string   `json:"name"` // the name of the binary (defaults to the last element of the package)
+	Args    []string `json:"args"` // arguments the binary is started with
+	Env     []string `json:"env"`  // KEY=VALUE pairs added to the environment of the binary
+	Dir     string   `json:"dir"`  // working directory of the binary, relative to the package directory
+
+	// Patterns (relative to the package directory) of the files the binary reads at
+	// startup. A change to one of them restarts this package without rebuilding it
+	RestartFiles []string `json:"restartFiles"`
+
+	Ready *Readiness `json:"ready"` // how to tell the binary has started up (see "ready.go")
+
+	BuildOptions // Overrides the BuildOptions in GobFlags for this package
+}
+
+// UnmarshalJSON accepts both the plain string and the object form of an entry
+func (w *WorldPackage) UnmarshalJSON(data []byte) error {
+	var pkg string
+	if err := json.Unmarshal(data, &pkg); err == nil {
+		*w = WorldPackage{Package: pkg}
+		return nil
+	}
+
+	// Use an alias so we don't recurse back into UnmarshalJSON
+	type worldPackage WorldPackage
+	var entry worldPackage
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return err
+	}
+	if entry.Package == "" {
+		return errors.New("world package is missing a \"package\"")
+	}
+
+	*w = WorldPackage(entry)
+	return nil
+}
+
+// buildOptions returns the build settings to use for the given package
+func (g *Gob) buildOptions(pkg string) BuildOptions {
+	options := g.FlagConfig.BuildOptions
+	if w, ok := g.WorldPkgs[pkg]; ok {
+		options = options.merge(w.BuildOptions)
+	}
+	return options
+}
+
+// binaryName returns the name of the binary built for the given package
+func (g *Gob) binaryName(pkg string) string {
+	if w, ok := g.WorldPkgs[pkg]; ok && w.Name != "" {
+		return w.Name
+	}
+	return filepath.Base(pkg)
+}
+
+// worldCommand returns the command that runs the binary of a World package
+// with its configured arguments, environment and working directory
+func (g *Gob) worldCommand(pkg string) *exec.Cmd {
+	cmd := exec.Command(filepath.Join(g.Config.BuildDir, g.binaryName(pkg)))
+	cmd.Stdout = g.Config.Stdout
+	cmd.Stderr = g.Config.Stderr
+
+	w, ok := g.WorldPkgs[pkg]
+	if !ok {
+		return cmd
+	}
+
+	cmd.Args = append(cmd.Args, w.Args...)
+	if len(w.Env) > 0 {
+		cmd.Env = append(os.Environ(), w.Env...)
+	}
+	if w.Dir != "" {
+		cmd.Dir = w.Dir
+		if !filepath.IsAbs(w.Dir) {
+			cmd.Dir = filepath.Join(g.pkgDir(pkg), w.Dir)
+		}
+	}
+
+	return cmd
+}
+
+// restartPkgsFor returns the World packages that declare any of the given files
+// in their RestartFiles. When none of them do, every World package is returned
+func (g *Gob) restartPkgsFor(files []string) []string {
+	var pkgs []string
+	for _, pkg := range g.World {
+		w, ok := g.WorldPkgs[pkg]
+		if !ok || len(w.RestartFiles) == 0 {
+			continue
+		}
+
+		rules := parseIgnoreRules(g.pkgDir(pkg), w.RestartFiles)
+	matchFiles:
+		for _, file := range files {
+			for _, rule := range rules {
+				if rule.match(file, false) {
+					pkgs = append(pkgs, pkg)
+					break matchFiles
+				}
+			}
+		}
+	}
+
+	if len(pkgs) == 0 {
+		return g.World
+	}
+	return pkgs
+}